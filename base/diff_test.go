@@ -0,0 +1,33 @@
+package base
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+	b := "1\n2\n3\n4\nfive\n6\n7\n8\n9\n10\n11\n"
+	want := `--- a
++++ b
+@@ -2,9 +2,10 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+ 9
+ 10
++11
+`
+	if got := UnifiedDiff("a", "b", a, b); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got := UnifiedDiff("a", "b", "", "new\n"); got != "--- a\n+++ b\n@@ -0,0 +1,1 @@\n+new\n" {
+		t.Errorf("new file diff:\n%s", got)
+	}
+	if got := UnifiedDiff("a", "b", a, a); got != "" {
+		t.Errorf("equal texts should give no diff, got:\n%s", got)
+	}
+}
@@ -10,8 +10,9 @@ import (
 	"github.com/lmittmann/tint"
 )
 
-// defaultLogFile returns the platform-specific default log file path.
-func defaultLogFile() (string, error) {
+// StateDir returns the platform-specific directory of ghost's state, like
+// logs and saved sessions.
+func StateDir() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("failed to get user home directory: %w", err)
@@ -19,17 +20,17 @@ func defaultLogFile() (string, error) {
 
 	switch runtime.GOOS {
 	case "windows":
-		// Windows: %LOCALAPPDATA%\ghost\logs\ghost.log
+		// Windows: %LOCALAPPDATA%\ghost
 		// Fall back to %USERPROFILE%\AppData\Local if LOCALAPPDATA is not set
 		appData := os.Getenv("LOCALAPPDATA")
 		if appData == "" {
 			appData = filepath.Join(home, "AppData", "Local")
 		}
-		return filepath.Join(appData, "ghost", "logs", "ghost.log"), nil
+		return filepath.Join(appData, "ghost"), nil
 
 	case "darwin":
-		// macOS: ~/Library/Logs/ghost/ghost.log
-		return filepath.Join(home, "Library", "Logs", "ghost", "ghost.log"), nil
+		// macOS: ~/Library/Application Support/ghost
+		return filepath.Join(home, "Library", "Application Support", "ghost"), nil
 
 	default: // Unix-like
 		// Try XDG_STATE_HOME first, then XDG_CACHE_HOME, then ~/.local/state
@@ -40,15 +41,34 @@ func defaultLogFile() (string, error) {
 				stateHome = filepath.Join(home, ".local", "state")
 			}
 		}
-		return filepath.Join(stateHome, "ghost", "logs", "ghost.log"), nil
+		return filepath.Join(stateHome, "ghost"), nil
 	}
 }
 
+// defaultLogFile returns the platform-specific default log file path.
+func defaultLogFile() (string, error) {
+	if runtime.GOOS == "darwin" {
+		// macOS: ~/Library/Logs/ghost/ghost.log
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get user home directory: %w", err)
+		}
+		return filepath.Join(home, "Library", "Logs", "ghost", "ghost.log"), nil
+	}
+	// Windows: %LOCALAPPDATA%\ghost\logs\ghost.log, Unix-like: $XDG_STATE_HOME/ghost/logs/ghost.log
+	stateDir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, "logs", "ghost.log"), nil
+}
+
 // InitLogger initializes a new logger that writes to a file and returns it
 // along with a closure function to close the opened log file.
 // The log file path is determined by LogFilePath(cfgPath).
 // The level parameter sets the minimum log level (debug, info, warn, error).
-func InitLogger(cfgPath string, level string) (*slog.Logger, func(), error) {
+// If redactor is not nil, secrets are masked in every log record.
+func InitLogger(cfgPath string, level string, redactor *Redactor) (*slog.Logger, func(), error) {
 	// Get the log file path
 	logPath, err := LogFilePath(cfgPath)
 	if err != nil {
@@ -72,9 +92,12 @@ func InitLogger(cfgPath string, level string) (*slog.Logger, func(), error) {
 
 	// Create a text handler that writes to the log file
 	// Using slog.NewTextHandler for file output to avoid ANSI escape codes from tint
-	handler := tint.NewHandler(logFile, &tint.Options{
+	var handler slog.Handler = tint.NewHandler(logFile, &tint.Options{
 		Level: lv,
 	})
+	if redactor != nil {
+		handler = &redactHandler{Handler: handler, redactor: redactor}
+	}
 
 	// Create the logger
 	logger := slog.New(handler)
@@ -0,0 +1,199 @@
+package base
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// secretPatterns are the built-in detectors. If a pattern has a group named
+// "secret", only that group is masked, so e.g. the key name stays readable.
+//
+// secret_assignment only matches quoted literals (key = "value", "key": "value")
+// and dotenv-style KEY=value lines, so code such as
+// tokenizer = AutoTokenizer.from_pretrained(name) is left alone.
+var secretPatterns = []struct {
+	name    string
+	pattern string
+}{
+	{"private_key", `-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`},
+	{"openai_key", `\bsk-[A-Za-z0-9_-]{20,}`},
+	{"aws_access_key", `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{"github_token", `\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`},
+	{"google_api_key", `\bAIza[0-9A-Za-z_-]{35}`},
+	{"slack_token", `\bxox[abprs]-[A-Za-z0-9-]{10,}`},
+	{"jwt", `\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`},
+	{"bearer_token", `(?i)\bbearer\s+(?P<secret>[A-Za-z0-9._~+/-]{20,}=*)`},
+	{"secret_assignment", `(?i)` + secretKey + `"?\s*[:=]\s*"(?P<secret>[^\s"]{8,})"`},
+	{"secret_assignment", `(?i)` + secretKey + `'?\s*[:=]\s*'(?P<secret>[^\s']{8,})'`},
+	{"secret_assignment", `(?im)^[ \t]*(?:export[ \t]+)?` + secretKey + `=(?P<secret>[^\s"'(),;]{8,})[ \t]*$`},
+}
+
+// secretKey matches the name of a key or variable that holds a secret.
+const secretKey = `[A-Z0-9_.-]*(?:api_?key|secret|token|passw(?:or)?d)[A-Z0-9_.-]*`
+
+// codeValue matches assigned values that are numbers or selectors such as
+// config.Token rather than secrets.
+var codeValue = regexp.MustCompile(`^(?:[0-9][0-9_.]*|[A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)+)$`)
+
+type redactPattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// Redactor masks secrets in text before it leaves the process, and counts
+// what it masked per detector. A nil *Redactor leaves text unchanged.
+type Redactor struct {
+	patterns []redactPattern
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewRedactor returns a Redactor with the built-in detectors plus the given
+// user regexes, which are reported as custom_1, custom_2, ...
+func NewRedactor(userPatterns []string) (*Redactor, error) {
+	r := &Redactor{counts: make(map[string]int)}
+	for _, p := range secretPatterns {
+		r.patterns = append(r.patterns, redactPattern{name: p.name, re: regexp.MustCompile(p.pattern)})
+	}
+	for i, p := range userPatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, redactPattern{name: fmt.Sprintf("custom_%d", i+1), re: re})
+	}
+	return r, nil
+}
+
+// Redact returns text with every detected secret replaced by [REDACTED:<detector>],
+// and secrets from the config (see RegisterSecret) by [REDACTED:config_secret].
+func (r *Redactor) Redact(text string) string {
+	if r == nil || text == "" {
+		return text
+	}
+	for _, secret := range registeredSecrets() {
+		if n := strings.Count(text, secret); n > 0 {
+			text = strings.ReplaceAll(text, secret, "[REDACTED:config_secret]")
+			r.mu.Lock()
+			r.counts["config_secret"] += n
+			r.mu.Unlock()
+		}
+	}
+	for _, p := range r.patterns {
+		matches := p.re.FindAllStringSubmatchIndex(text, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		secretGroup := p.re.SubexpIndex("secret")
+
+		var sb strings.Builder
+		last, redacted := 0, 0
+		for _, m := range matches {
+			start, end := m[0], m[1]
+			if secretGroup > 0 && m[2*secretGroup] >= 0 {
+				start, end = m[2*secretGroup], m[2*secretGroup+1]
+			}
+			if strings.HasPrefix(text[start:end], "[REDACTED:") {
+				continue // already masked by an earlier detector
+			}
+			if p.name == "secret_assignment" && codeValue.MatchString(text[start:end]) {
+				continue
+			}
+			sb.WriteString(text[last:start])
+			sb.WriteString("[REDACTED:" + p.name + "]")
+			last = end
+			redacted++
+		}
+		if redacted == 0 {
+			continue
+		}
+		sb.WriteString(text[last:])
+		text = sb.String()
+
+		r.mu.Lock()
+		r.counts[p.name] += redacted
+		r.mu.Unlock()
+	}
+	return text
+}
+
+// Summary describes how many secrets were redacted per detector,
+// or returns "" if none were.
+func (r *Redactor) Summary() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	total := 0
+	names := make([]string, 0, len(r.counts))
+	for name, n := range r.counts {
+		total += n
+		names = append(names, name)
+	}
+	if total == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, r.counts[name]))
+	}
+	return fmt.Sprintf("Redacted %d secret(s): %s", total, strings.Join(parts, ", "))
+}
+
+// redactHandler is a slog.Handler masking secrets in messages and attribute values.
+type redactHandler struct {
+	slog.Handler
+	redactor *Redactor
+}
+
+func (h *redactHandler) Handle(ctx context.Context, rec slog.Record) error {
+	out := slog.NewRecord(rec.Time, rec.Level, h.redactor.Redact(rec.Message), rec.PC)
+	rec.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.redactAttr(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, out)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		redacted = append(redacted, h.redactAttr(a))
+	}
+	return &redactHandler{Handler: h.Handler.WithAttrs(redacted), redactor: h.redactor}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{Handler: h.Handler.WithGroup(name), redactor: h.redactor}
+}
+
+func (h *redactHandler) redactAttr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, h.redactor.Redact(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		redacted := make([]any, 0, len(group))
+		for _, ga := range group {
+			redacted = append(redacted, h.redactAttr(ga))
+		}
+		return slog.Group(a.Key, redacted...)
+	case slog.KindAny:
+		// values such as tool arguments are logged through their string form
+		s := fmt.Sprintf("%+v", v.Any())
+		if redacted := h.redactor.Redact(s); redacted != s {
+			return slog.String(a.Key, redacted)
+		}
+	}
+	return a
+}
@@ -0,0 +1,95 @@
+package base
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// secretRef matches the references ResolveSecret resolves. file:// URLs are
+// not references, so that such values can be passed as is.
+var secretRef = regexp.MustCompile(`^(?:env:[A-Za-z_][A-Za-z0-9_]*|file:(?:[^/]|/[^/]).*|cmd:.+)$`)
+
+// IsSecretRef reports whether s refers to a secret rather than being one.
+func IsSecretRef(s string) bool {
+	return secretRef.MatchString(s)
+}
+
+// ResolveSecret returns the value a config value refers to:
+//
+//	env:NAME     the environment variable NAME
+//	file:PATH    the contents of the file, trimmed
+//	cmd:COMMAND  the output of the shell command, trimmed, e.g. "cmd:pass show openai"
+//
+// Other values are returned as they are. Resolved values are registered with
+// RegisterSecret, errors never contain them.
+func ResolveSecret(ctx context.Context, ref string) (string, error) {
+	if !IsSecretRef(ref) {
+		return ref, nil
+	}
+	kind, arg, _ := strings.Cut(ref, ":")
+
+	var value string
+	switch kind {
+	case "env":
+		v, ok := os.LookupEnv(arg)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", arg)
+		}
+		value = v
+	case "file":
+		b, err := os.ReadFile(arg)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		value = string(b)
+	case "cmd":
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "sh", "-c", arg)
+		cmd.Stderr = os.Stderr // prompts of password managers
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("secret command %q failed: %w", arg, err)
+		}
+		value = string(out)
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", fmt.Errorf("secret %s is empty", ref)
+	}
+	RegisterSecret(value)
+	return value, nil
+}
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// RegisterSecret makes every Redactor mask value, e.g. an API key from the config.
+// Values shorter than 8 characters are ignored, they would mask too much.
+func RegisterSecret(value string) {
+	if len(value) < 8 {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, s := range secrets {
+		if s == value {
+			return
+		}
+	}
+	secrets = append(secrets, value)
+}
+
+func registeredSecrets() []string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	return secrets
+}
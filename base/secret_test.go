@@ -0,0 +1,30 @@
+package base
+
+import (
+	"context"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	t.Setenv("GHOST_TEST_SECRET", "s3cret-value")
+	for ref, want := range map[string]string{
+		"env:GHOST_TEST_SECRET":     "s3cret-value",
+		"cmd:echo ' from-command '": "from-command",
+		"literal-key":               "literal-key",
+		"file:///etc/hosts":         "file:///etc/hosts", // URLs are values, not references
+		"env:":                      "env:",
+	} {
+		got, err := ResolveSecret(context.Background(), ref)
+		if err != nil || got != want {
+			t.Errorf("ResolveSecret(%q) = %q, %v, want %q", ref, got, err, want)
+		}
+	}
+	if _, err := ResolveSecret(context.Background(), "env:GHOST_TEST_UNSET"); err == nil {
+		t.Error("unset variable resolved")
+	}
+
+	r, _ := NewRedactor(nil)
+	if got := r.Redact("key is s3cret-value"); got != "key is [REDACTED:config_secret]" {
+		t.Errorf("resolved secret not redacted: %q", got)
+	}
+}
@@ -27,7 +27,7 @@ func StartProgressSpinner(taskName string, total int) *Spinner {
 	}
 
 	if term.IsTerminal(int(os.Stdout.Fd())) { // only tick update spinner when in terminal
-		s.update(s.frame, s.finished)
+		s.update(s.frame, s.finished, s.message)
 
 		go func() {
 			ticker := time.NewTicker(defaultSpinnerInterval)
@@ -39,9 +39,9 @@ func StartProgressSpinner(taskName string, total int) *Spinner {
 				case <-ticker.C:
 					s.mu.Lock()
 					s.frame++
-					currentFrame, currentFinished := s.frame, s.finished
+					currentFrame, currentFinished, currentMessage := s.frame, s.finished, s.message
 					s.mu.Unlock()
-					s.update(currentFrame, currentFinished)
+					s.update(currentFrame, currentFinished, currentMessage)
 				}
 			}
 		}()
@@ -55,6 +55,7 @@ type Spinner struct {
 
 	mu                     sync.Mutex
 	frame, finished, total int
+	message                string
 	ctx                    context.Context
 	cancel                 context.CancelFunc
 }
@@ -62,17 +63,36 @@ type Spinner struct {
 func (s *Spinner) Incr() {
 	s.mu.Lock()
 	s.finished++
-	currentFrame, currentFinished := s.frame, s.finished
+	currentFrame, currentFinished, currentMessage := s.frame, s.finished, s.message
 	shouldStop := (s.total > 0 && s.finished >= s.total)
 	s.mu.Unlock()
 
-	s.update(currentFrame, currentFinished)
+	s.update(currentFrame, currentFinished, currentMessage)
 	if shouldStop {
 		s.Stop()
 	}
 }
 
-func (s *Spinner) update(currentFrame, currentFinished int) {
+// SetMessage sets a status text shown after the spinner, e.g. progress
+// reported by the task itself.
+func (s *Spinner) SetMessage(message string) {
+	s.mu.Lock()
+	if s.message == message {
+		s.mu.Unlock()
+		return
+	}
+	s.message = message
+	currentFrame, currentFinished := s.frame, s.finished
+	s.mu.Unlock()
+
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		s.update(currentFrame, currentFinished, message)
+	} else {
+		fmt.Printf("%s %s\n", s.taskName, message)
+	}
+}
+
+func (s *Spinner) update(currentFrame, currentFinished int, message string) {
 	frameText := spinners[currentFrame%len(spinners)]
 	if s.total != 0 && currentFinished >= s.total {
 		frameText = "✅   "
@@ -84,7 +104,8 @@ func (s *Spinner) update(currentFrame, currentFinished int) {
 	}
 
 	if term.IsTerminal(int(os.Stdout.Fd())) {
-		fmt.Printf("\r%s %s %s", s.taskName, progressText, frameText)
+		// clear the rest of the line, a previous message may have been longer
+		fmt.Printf("\r%s %s %s %s\033[K", s.taskName, progressText, frameText, message)
 	} else {
 		if s.total != 0 {
 			fmt.Printf("%s %s\n", s.taskName, progressText)
@@ -102,12 +123,12 @@ func (s *Spinner) Stop() {
 	default:
 		s.cancel()
 		// Capture final state for update *after* cancelling
-		currentFrame, currentFinished := s.frame, s.finished
+		currentFrame, currentFinished, currentMessage := s.frame, s.finished, s.message
 		s.mu.Unlock() // Unlock before potentially long-running I/O (fmt.Print)
 
 		if term.IsTerminal(int(os.Stdout.Fd())) {
-			s.update(currentFrame, currentFinished) // Show final state
-			fmt.Println()                           // Move to next line
+			s.update(currentFrame, currentFinished, currentMessage) // Show final state
+			fmt.Println()                                           // Move to next line
 		} else {
 			// For non-TTY, if it's a determinate spinner that was completed,
 			// the last update in Incr would have printed the final count.
@@ -1,27 +1,234 @@
 package cli
 
-import "flag"
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
 
 // Flags holds the command-line arguments.
 type Flags struct {
+	// Command is the subcommand, e.g. "chat", "run" or "mcp ping".
+	// A bare "ghost -p prompt.md" is "chat".
+	Command string
+	// Args are the arguments after the command, e.g. the prompt of "ghost run".
+	Args []string
+
 	PromptFile string
 	LLMName    string
 	CfgPath    string
 	ModelName  string
+	HTTPAddr   string
+	Origin     bool
+	JSON       bool
+	Profile    string
+
+	// Generation params overriding those of the config, unset if nil or empty.
+	Temperature     *float64
+	TopP            *float64
+	MaxOutputTokens int
+	Seed            *int64
+	StopSequences   []string
+	ReasoningEffort string
+	ThinkingBudget  *int
+}
+
+// flag groups a command accepts, besides -c
+const (
+	llmFlags     = 1 << iota // -l, -m and the generation params
+	promptFlags              // -p
+	profileFlags             // --profile
+	httpFlags                // -http
+	originFlags              // --origin
+	jsonFlags                // --json
+)
+
+type command struct {
+	name  string
+	args  string // synopsis of the arguments
+	help  string
+	flags int
+}
+
+var commands = []command{
+	{"chat", "", "Chat with an LLM that uses the tools of the MCP servers (the default)", llmFlags | promptFlags | profileFlags},
+	{"run", "[prompt...]", "Run one prompt, given as arguments or with -p, and print the reply", llmFlags | promptFlags | profileFlags},
+	{"tools list", "", "List the tools of the MCP servers", profileFlags | jsonFlags},
+	{"tools inspect", "<tool>", "Print the input schema and annotations of a tool, and how it is sent to each LLM type", profileFlags | jsonFlags},
+	{"mcp ping", "[mcp...]", "Start MCP servers and check that they answer", profileFlags},
+	{"mcp serve", "", "Serve an ask_ghost tool as MCP server on stdio", llmFlags | profileFlags},
+	{"mcp gateway", "", "Serve the tools, resources and prompts of all MCP servers as one MCP server", profileFlags | httpFlags},
+	{"config show", "", "Print the merged config", originFlags},
+	{"config validate", "", "Check the config for errors", 0},
+	{"sessions ls", "", "List the saved chat sessions", 0},
+}
+
+// legacyCommands are the former names of commands.
+var legacyCommands = map[string]string{
+	"mcp-serve":   "mcp serve",
+	"mcp-gateway": "mcp gateway",
 }
 
 // ParseFlags parses the command-line arguments and returns them in a Flags struct.
+// It exits after printing help or a usage error.
 func ParseFlags() *Flags {
-	promptFile := flag.String("p", "prompt.md", "prompt template file")
-	llmName := flag.String("l", "", "LLM to use (openai|gemini)")
-	cfgPath := flag.String("c", "", "path to config file")
-	modelName := flag.String("m", "", "LLM model to use")
-	flag.Parse()
+	f, err := Parse(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	return f
+}
+
+// Parse parses the arguments of ghost, without the program name. Help and
+// usage errors are written to w; on help, flag.ErrHelp is returned.
+func Parse(args []string, w io.Writer) (*Flags, error) {
+	if len(args) > 0 && (args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+		if len(args) > 1 && args[0] == "help" {
+			if cmd, _, err := findCommand(args[1:]); err == nil {
+				newFlagSet(cmd, &Flags{}, w).Usage()
+				return nil, flag.ErrHelp
+			}
+		}
+		printUsage(w)
+		return nil, flag.ErrHelp
+	}
+
+	cmd, args, err := findCommand(args)
+	if err != nil {
+		printUsage(w)
+		return nil, err
+	}
+
+	f := &Flags{Command: cmd.name}
+	fs := newFlagSet(cmd, f, w)
+	// flags may follow arguments, e.g. "ghost run fix the tests -l gemini"
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		f.Args = append(f.Args, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if cmd.args == "" && len(f.Args) > 0 {
+		fs.Usage()
+		return nil, fmt.Errorf("ghost %s takes no arguments, got %q", cmd.name, strings.Join(f.Args, " "))
+	}
+	if required := strings.Count(cmd.args, "<"); required > 0 && len(f.Args) != required {
+		fs.Usage()
+		return nil, fmt.Errorf("ghost %s needs %s", cmd.name, cmd.args)
+	}
+	return f, nil
+}
 
-	return &Flags{
-		PromptFile: *promptFile,
-		LLMName:    *llmName,
-		CfgPath:    *cfgPath,
-		ModelName:  *modelName,
+// findCommand returns the command named by the first arguments and the rest.
+func findCommand(args []string) (command, []string, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return commands[0], args, nil // bare "ghost -p prompt.md" chats
 	}
+	name, rest := args[0], args[1:]
+	if legacy, ok := legacyCommands[name]; ok {
+		name = legacy
+	} else if i := slices.IndexFunc(commands, func(c command) bool { return strings.HasPrefix(c.name, name+" ") }); i >= 0 {
+		// a group like "tools", the subcommand follows
+		var subs []string
+		for _, c := range commands {
+			if group, sub, _ := strings.Cut(c.name, " "); group == name {
+				subs = append(subs, sub)
+			}
+		}
+		if len(rest) == 0 || strings.HasPrefix(rest[0], "-") {
+			return command{}, nil, fmt.Errorf("ghost %s needs a subcommand: %s", name, strings.Join(subs, ", "))
+		}
+		if !slices.Contains(subs, rest[0]) {
+			return command{}, nil, fmt.Errorf("unknown subcommand %q of ghost %s, use one of: %s", rest[0], name, strings.Join(subs, ", "))
+		}
+		name, rest = name+" "+rest[0], rest[1:]
+	}
+	i := slices.IndexFunc(commands, func(c command) bool { return c.name == name })
+	if i < 0 {
+		return command{}, nil, fmt.Errorf("unknown command %q", name)
+	}
+	return commands[i], rest, nil
 }
+
+// newFlagSet returns the flag set of cmd, storing into f.
+func newFlagSet(cmd command, f *Flags, w io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("ghost "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(w)
+	fs.Usage = func() {
+		fmt.Fprintf(w, "Usage: ghost %s [flags] %s\n\n%s.\n\nFlags:\n", cmd.name, cmd.args, cmd.help)
+		fs.PrintDefaults()
+	}
+
+	fs.StringVar(&f.CfgPath, "c", "", "path to config file")
+	if cmd.flags&promptFlags != 0 {
+		fs.StringVar(&f.PromptFile, "p", "", "prompt template file (default the profile's PromptFile, or prompt.md)")
+	}
+	if cmd.flags&llmFlags != 0 {
+		fs.StringVar(&f.LLMName, "l", "", "LLM to use, by name in the config")
+		fs.StringVar(&f.ModelName, "m", "", "LLM model to use")
+
+		fs.Func("temperature", "sampling temperature, e.g. 0 for reproducible output", parseInto(&f.Temperature, parseFloat))
+		fs.Func("top-p", "nucleus sampling probability mass", parseInto(&f.TopP, parseFloat))
+		fs.IntVar(&f.MaxOutputTokens, "max-tokens", 0, "maximum number of tokens of each reply")
+		fs.Func("seed", "sampling seed, for reproducible output where the API supports it", parseInto(&f.Seed, parseInt64))
+		fs.Func("stop", "stop sequence, may be repeated", func(s string) error {
+			f.StopSequences = append(f.StopSequences, s)
+			return nil
+		})
+		fs.StringVar(&f.ReasoningEffort, "reasoning-effort", "", "reasoning effort of reasoning models (low|medium|high)")
+		fs.Func("thinking-budget", "thinking tokens of Gemini models, 0 disables thinking, -1 lets the model decide", parseInto(&f.ThinkingBudget, strconv.Atoi))
+	}
+	if cmd.flags&profileFlags != 0 {
+		fs.StringVar(&f.Profile, "profile", "", "profile of the config to use, see [[Profiles]]")
+	}
+	if cmd.flags&httpFlags != 0 {
+		fs.StringVar(&f.HTTPAddr, "http", "", "serve over HTTP (SSE) on this address instead of stdio")
+	}
+	if cmd.flags&originFlags != 0 {
+		fs.BoolVar(&f.Origin, "origin", false, "show the file:line or environment variable of each value")
+	}
+	if cmd.flags&jsonFlags != 0 {
+		fs.BoolVar(&f.JSON, "json", false, "print JSON")
+	}
+	return fs
+}
+
+// printUsage lists the commands.
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: ghost <command> [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-28s %s\n", strings.TrimSpace(c.name+" "+c.args), c.help)
+	}
+	fmt.Fprintf(w, "\nWithout a command ghost chats, e.g. ghost -p prompt.md.\n"+
+		"Run \"ghost <command> --help\" for the flags of a command.\n")
+}
+
+// parseInto returns a flag.Func callback that sets *dst to the parsed value,
+// so unset flags can be told from zero values.
+func parseInto[T any](dst **T, parse func(string) (T, error)) func(string) error {
+	return func(s string) error {
+		v, err := parse(s)
+		if err != nil {
+			return err
+		}
+		*dst = &v
+		return nil
+	}
+}
+
+func parseFloat(s string) (float64, error) { return strconv.ParseFloat(s, 64) }
+
+func parseInt64(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) }
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		args    []string
+		command string
+		check   func(f *Flags) bool
+	}{
+		{[]string{"-p", "prompt.md", "-l", "gemini"}, "chat", func(f *Flags) bool { return f.PromptFile == "prompt.md" && f.LLMName == "gemini" }},
+		{nil, "chat", nil},
+		{[]string{"mcp-serve", "-c", "x.toml"}, "mcp serve", func(f *Flags) bool { return f.CfgPath == "x.toml" }},
+		{[]string{"mcp", "gateway", "-http", ":8080"}, "mcp gateway", func(f *Flags) bool { return f.HTTPAddr == ":8080" }},
+		{[]string{"config", "show", "--origin"}, "config show", func(f *Flags) bool { return f.Origin }},
+		{[]string{"run", "fix", "the", "tests", "-l", "openai"}, "run", func(f *Flags) bool {
+			return slices.Equal(f.Args, []string{"fix", "the", "tests"}) && f.LLMName == "openai"
+		}},
+		{[]string{"tools", "inspect", "git_status", "--json"}, "tools inspect", func(f *Flags) bool {
+			return slices.Equal(f.Args, []string{"git_status"}) && f.JSON
+		}},
+		{[]string{"mcp", "ping", "git", "fs"}, "mcp ping", func(f *Flags) bool { return slices.Equal(f.Args, []string{"git", "fs"}) }},
+	}
+	for _, tt := range tests {
+		f, err := Parse(tt.args, io.Discard)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.args, err)
+			continue
+		}
+		if f.Command != tt.command || (tt.check != nil && !tt.check(f)) {
+			t.Errorf("Parse(%q) = %+v, want command %q", tt.args, f, tt.command)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"frobnicate"},
+		{"tools"},
+		{"tools", "drop"},
+		{"tools", "inspect"},
+		{"tools", "inspect", "a", "b"},
+		{"config", "validate", "extra"},
+		{"config", "show", "-l", "openai"}, // flag of another command
+	} {
+		if _, err := Parse(args, io.Discard); err == nil || errors.Is(err, flag.ErrHelp) {
+			t.Errorf("Parse(%q) = %v, want usage error", args, err)
+		}
+	}
+
+	var usage strings.Builder
+	if _, err := Parse([]string{"run", "--help"}, &usage); !errors.Is(err, flag.ErrHelp) || !strings.Contains(usage.String(), "Usage: ghost run") {
+		t.Errorf("ghost run --help = %v, usage %q", err, usage.String())
+	}
+	usage.Reset()
+	if _, err := Parse([]string{"help", "mcp", "ping"}, &usage); !errors.Is(err, flag.ErrHelp) || !strings.Contains(usage.String(), "Usage: ghost mcp ping") {
+		t.Errorf("ghost help mcp ping = %v, usage %q", err, usage.String())
+	}
+	usage.Reset()
+	if _, err := Parse([]string{"--help"}, &usage); !errors.Is(err, flag.ErrHelp) || !strings.Contains(usage.String(), "sessions ls") {
+		t.Errorf("ghost --help = %v, usage %q", err, usage.String())
+	}
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path"
+	"path/filepath"
 
 	"github.com/kk2simon/ghost-cli/llm"
 	"github.com/kk2simon/ghost-cli/tools"
@@ -15,44 +19,105 @@ type Config struct {
 	LogLevel string
 	LogPath  string
 
-	LLMs []llm.LLMConfig
-	Mcps []tools.McpConfig
-}
+	// RedactPatterns are regexes masked in prompts, tool results and logs,
+	// in addition to the built-in secret detectors.
+	RedactPatterns []string
 
-func ParseConfig(flagCfgPath string) (Config, error) {
+	// MaxToolFailures is the number of consecutive failed tool calls after
+	// which the LLM is told to stop calling tools, see tools.Runtime.
+	MaxToolFailures int
 
-	tryPaths := []string{}
-	if envCfgPath := os.Getenv("GHOST_CONFIG_TOML_FILE"); envCfgPath != "" {
-		tryPaths = append(tryPaths, envCfgPath)
-	}
-	if flagCfgPath != "" { // Add this block
-		tryPaths = append(tryPaths, flagCfgPath)
-	}
+	LLMs     []llm.LLMConfig
+	Mcps     []tools.McpConfig
+	Profiles []Profile
+
+	// merged holds the merged layers, and origins where each value came
+	// from, by key path such as "LLMs[openai].APIKey".
+	merged  map[string]any
+	origins map[string]string
+	// layerDiagnostics are the problems found in single files, see validateConfig.
+	layerDiagnostics []diagnostic
+}
 
+// configPaths returns the config files merged by ParseConfig, the most important last.
+func configPaths(flagCfgPath string) []string {
 	cwd, _ := os.Getwd()
 	home, _ := os.UserHomeDir()
-	tryPaths = append(tryPaths,
-		path.Join(cwd, ".ghost", "config.toml"),
-		path.Join(home, ".ghost", "config.toml"),
+	paths := []string{
 		path.Join(home, ".config", "ghost", "config.toml"),
-	)
-
-	var f *os.File
-	var err error
-	for _, path := range tryPaths {
-		f, err = os.Open(path)
-		if err == nil {
-			fmt.Println("Reading config file:", path)
-			break
+		path.Join(home, ".ghost", "config.toml"),
+		path.Join(cwd, ".ghost", "config.toml"),
+	}
+	if flagCfgPath != "" {
+		paths = append(paths, flagCfgPath)
+	}
+	if envCfgPath := os.Getenv("GHOST_CONFIG_TOML_FILE"); envCfgPath != "" {
+		paths = append(paths, envCfgPath)
+	}
+	return paths
+}
+
+// ParseConfig merges the config files found among ~/.config/ghost, ~/.ghost,
+// ./.ghost, the -c flag and GHOST_CONFIG_TOML_FILE, in that order, and applies
+// GHOST_* environment overrides on top. Later files override earlier ones,
+// entries of LLMs, Mcps and Profiles are merged by Name.
+func ParseConfig(flagCfgPath string) (Config, error) {
+	tryPaths := configPaths(flagCfgPath)
+
+	merged := map[string]any{}
+	origins := map[string]string{}
+	seen := map[string]bool{}
+	found := false
+	var diagnostics []diagnostic
+	for _, p := range tryPaths {
+		if abs, err := filepath.Abs(p); err == nil {
+			if seen[abs] {
+				continue // e.g. ./.ghost when running in the home directory
+			}
+			seen[abs] = true
+		}
+
+		b, err := os.ReadFile(p)
+		if errors.Is(err, fs.ErrNotExist) && p != flagCfgPath {
+			continue
 		}
+		if err != nil {
+			return Config{}, fmt.Errorf("Failed to read config(%s): %v", p, err)
+		}
+		// decoding the file on its own reports type errors with their line
+		md, err := toml.Decode(string(b), &Config{})
+		if err != nil {
+			return Config{}, fmt.Errorf("Failed to decode config(%s): %v", p, err)
+		}
+		var layer map[string]any
+		if _, err := toml.Decode(string(b), &layer); err != nil {
+			return Config{}, fmt.Errorf("Failed to decode config(%s): %v", p, err)
+		}
+		fmt.Println("Reading config file:", p)
+		found = true
+		lines := keyLines(string(b))
+		diagnostics = append(diagnostics, checkLayer(p, lines, md, layer)...)
+		mergeLayer(merged, layer, origins, p, lines)
 	}
-	if f == nil {
+	if !found {
 		return Config{}, fmt.Errorf("Failed to find config file in any known location. Tried: %v", tryPaths)
 	}
-	defer f.Close()
+
+	if err := applyEnvOverrides(merged, origins, os.Environ()); err != nil {
+		return Config{}, err
+	}
+
+	// decode the merged layers like a single file
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(merged); err != nil {
+		return Config{}, fmt.Errorf("Failed to merge config: %v", err)
+	}
 	var cfg Config
-	if _, err := toml.NewDecoder(f).Decode(&cfg); err != nil {
-		return Config{}, fmt.Errorf("Failed to decode config(%s): %v", f.Name(), err)
+	if _, err := toml.Decode(buf.String(), &cfg); err != nil {
+		return Config{}, fmt.Errorf("Failed to decode merged config: %v", err)
 	}
+	cfg.merged = merged
+	cfg.origins = origins
+	cfg.layerDiagnostics = diagnostics
 	return cfg, nil
 }
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/kk2simon/ghost-cli/base"
+)
+
+// runConfig runs "ghost config show" or "ghost config validate".
+func runConfig(cfg Config, command string, withOrigin bool, stdout io.Writer) error {
+	switch command {
+	case "config validate":
+		diags := validateConfig(cfg)
+		errs := 0
+		for _, d := range diags {
+			fmt.Fprintln(stdout, d)
+			if !d.warning {
+				errs++
+			}
+		}
+		if errs > 0 {
+			return fmt.Errorf("%d error(s) in config", errs)
+		}
+		fmt.Fprintf(stdout, "Config OK, %d warning(s)\n", len(diags))
+		return nil
+	case "config show":
+		showConfig(stdout, cfg, withOrigin)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// secretKey matches keys whose values showConfig masks, also as names in Env
+// entries and Headers.
+var secretKey = regexp.MustCompile(`(?i)(api[-_]?key|token|secret|password|authorization)s?$`)
+
+// showConfig prints the merged config as TOML, secrets masked, optionally
+// with the origin of each value as comment.
+func showConfig(w io.Writer, cfg Config, withOrigin bool) {
+	var printTable func(m map[string]any, header, named string)
+	printTable = func(m map[string]any, header, named string) {
+		var tables, arrays []string
+		for _, k := range sortedKeys(m) {
+			switch v := m[k].(type) {
+			case map[string]any:
+				tables = append(tables, k)
+			case []map[string]any:
+				arrays = append(arrays, k)
+			default:
+				line := fmt.Sprintf("%s = %s", k, tomlValue(k, v))
+				if o, ok := cfg.origins[joinKey(named, k)]; ok && withOrigin {
+					line += "  # " + o
+				}
+				fmt.Fprintln(w, line)
+			}
+		}
+		for _, k := range tables {
+			fmt.Fprintf(w, "\n[%s]\n", joinKey(header, k))
+			printTable(m[k].(map[string]any), joinKey(header, k), joinKey(named, k))
+		}
+		for _, k := range arrays {
+			for i, e := range m[k].([]map[string]any) {
+				name, _ := e["Name"].(string)
+				fmt.Fprintf(w, "\n[[%s]]\n", joinKey(header, k))
+				printTable(e, joinKey(header, k), entryKey(joinKey(named, k), name, i))
+			}
+		}
+	}
+	printTable(cfg.merged, "", "")
+}
+
+// sortedKeys returns the keys of m with Name first.
+func sortedKeys(m map[string]any) []string {
+	keys := slices.Sorted(maps.Keys(m))
+	if i := slices.Index(keys, "Name"); i > 0 {
+		keys = append([]string{"Name"}, slices.Delete(keys, i, i+1)...)
+	}
+	return keys
+}
+
+// tomlValue formats a config value, masking secrets.
+func tomlValue(key string, v any) string {
+	switch v := v.(type) {
+	case string:
+		if secretKey.MatchString(key) && v != "" && !base.IsSecretRef(v) {
+			return `"****"`
+		}
+		return fmt.Sprintf("%q", v)
+	case []any:
+		itemKey := "" // lists of secrets, like APIKeys, are masked item by item
+		if key != "Env" && secretKey.MatchString(key) {
+			itemKey = key
+		}
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = tomlValue(itemKey, item)
+			if s, ok := item.(string); ok && key == "Env" {
+				if name, value, _ := strings.Cut(s, "="); secretKey.MatchString(name) && !base.IsSecretRef(value) {
+					items[i] = fmt.Sprintf("%q", name+"=****")
+				}
+			}
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case []string:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return tomlValue(key, items)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mergeLayer merges the decoded config file src into dst. Tables are merged
+// key by key, arrays of tables (LLMs, Mcps) by the Name of their entries, and
+// other values are replaced. The origin of each replaced value is recorded
+// as file:line, found in lines from keyLines.
+func mergeLayer(dst, src map[string]any, origins map[string]string, file string, lines map[string]int) {
+	var mergeTable func(dst, src map[string]any, named, indexed string)
+	mergeTable = func(dst, src map[string]any, named, indexed string) {
+		for k, v := range src {
+			keyNamed, keyIndexed := joinKey(named, k), joinKey(indexed, k)
+			switch v := v.(type) {
+			case map[string]any:
+				sub, ok := dst[k].(map[string]any)
+				if !ok {
+					sub = map[string]any{}
+					dst[k] = sub
+				}
+				mergeTable(sub, v, keyNamed, keyIndexed)
+			case []map[string]any:
+				entries, _ := dst[k].([]map[string]any)
+				for i, e := range v {
+					name, _ := e["Name"].(string)
+					j := indexByName(entries, name)
+					if j < 0 {
+						entries = append(entries, map[string]any{})
+						j = len(entries) - 1
+					}
+					mergeTable(entries[j], e, entryKey(keyNamed, name, j), fmt.Sprintf("%s[%d]", keyIndexed, i))
+				}
+				dst[k] = entries
+			default:
+				dst[k] = v
+				origins[keyNamed] = origin(file, lines, keyIndexed)
+			}
+		}
+	}
+	mergeTable(dst, src, "", "")
+}
+
+// indexByName returns the index of the entry called name, or -1.
+// Entries without a name never match.
+func indexByName(entries []map[string]any, name string) int {
+	if name == "" {
+		return -1
+	}
+	for i, e := range entries {
+		if e["Name"] == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// entryKey names an entry of an array of tables by its Name, or its index if it has none.
+func entryKey(array, name string, i int) string {
+	if name == "" {
+		return fmt.Sprintf("%s[%d]", array, i)
+	}
+	return fmt.Sprintf("%s[%s]", array, name)
+}
+
+// origin returns file:line of the key, or of the closest table around it,
+// e.g. for keys of inline tables.
+func origin(file string, lines map[string]int, key string) string {
+	for key != "" {
+		if line, ok := lines[key]; ok {
+			return fmt.Sprintf("%s:%d", file, line)
+		}
+		i := strings.LastIndexAny(key, ".[")
+		if i < 0 {
+			break
+		}
+		key = key[:i]
+	}
+	return file
+}
+
+var (
+	tomlHeader = regexp.MustCompile(`^(\[\[?)\s*([^\[\]]+?)\s*\]`)
+	tomlKey    = regexp.MustCompile(`^([A-Za-z0-9_-]+|"[^"]*"|'[^']*')(\s*\.\s*([A-Za-z0-9_-]+|"[^"]*"|'[^']*'))*\s*=`)
+)
+
+// keyLines returns the line numbers of the keys and table headers of a TOML
+// file, by key path with array tables indexed, e.g. "LLMs[0].APIKey".
+// It is a line scanner, enough to tell users where to look.
+func keyLines(text string) map[string]int {
+	lines := map[string]int{}
+	counts := map[string]int{} // entries of array tables seen so far
+	resolve := func(dotted string) string {
+		p := ""
+		for _, seg := range splitKey(dotted) {
+			p = joinKey(p, seg)
+			if c, ok := counts[p]; ok {
+				p = fmt.Sprintf("%s[%d]", p, c-1)
+			}
+		}
+		return p
+	}
+
+	prefix, multiline := "", ""
+	for i, line := range strings.Split(text, "\n") {
+		if multiline != "" {
+			if strings.Count(line, multiline)%2 == 1 {
+				multiline = ""
+			}
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if m := tomlHeader.FindStringSubmatch(line); m != nil {
+			if m[1] == "[[" {
+				segs := splitKey(m[2])
+				array := joinKey(resolve(strings.Join(segs[:len(segs)-1], ".")), segs[len(segs)-1])
+				prefix = fmt.Sprintf("%s[%d]", array, counts[array])
+				counts[array]++
+			} else {
+				prefix = resolve(m[2])
+			}
+			lines[prefix] = i + 1
+			continue
+		}
+		if m := tomlKey.FindString(line); m != "" {
+			key := strings.Join(splitKey(strings.TrimSuffix(m, "=")), ".")
+			lines[joinKey(prefix, key)] = i + 1
+			for _, q := range []string{`"""`, `'''`} {
+				if strings.Count(line, q)%2 == 1 {
+					multiline = q
+				}
+			}
+		}
+	}
+	return lines
+}
+
+// splitKey splits a dotted TOML key into its unquoted parts.
+func splitKey(dotted string) []string {
+	var segs []string
+	for _, seg := range strings.Split(dotted, ".") {
+		seg = strings.Trim(strings.TrimSpace(seg), `"'`)
+		if seg != "" {
+			segs = append(segs, seg)
+		}
+	}
+	return segs
+}
+
+// applyEnvOverrides sets config values from GHOST_* environment variables:
+// GHOST_<FIELD> for top-level fields, e.g. GHOST_LOGLEVEL=debug, and
+// GHOST_<LIST>_<NAME>_<FIELD> for fields of named entries, e.g.
+// GHOST_LLMS_OPENAI_APIKEY=... Names are upper-cased, with characters other
+// than letters and digits replaced by "_". Nested tables can't be set.
+func applyEnvOverrides(merged map[string]any, origins map[string]string, environ []string) error {
+	cfgType := reflect.TypeOf(Config{})
+	for _, kv := range environ {
+		envKey, value, _ := strings.Cut(kv, "=")
+		rest, ok := strings.CutPrefix(envKey, "GHOST_")
+		if !ok || envKey == "GHOST_CONFIG_TOML_FILE" {
+			continue
+		}
+
+		for i := 0; i < cfgType.NumField(); i++ {
+			f := cfgType.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.Struct {
+				entryRest, ok := strings.CutPrefix(rest, strings.ToUpper(f.Name)+"_")
+				if !ok {
+					continue
+				}
+				entries, _ := merged[f.Name].([]map[string]any)
+				for _, e := range entries {
+					name, _ := e["Name"].(string)
+					field, ok := strings.CutPrefix(entryRest, envName(name)+"_")
+					if name == "" || !ok {
+						continue
+					}
+					ef, ok := fieldByEnvName(f.Type.Elem(), field)
+					if !ok {
+						continue
+					}
+					v, err := envValue(ef.Type, value)
+					if err != nil {
+						return fmt.Errorf("Failed to apply %s: %v", envKey, err)
+					}
+					e[ef.Name] = v
+					origins[fmt.Sprintf("%s[%s].%s", f.Name, name, ef.Name)] = "env " + envKey
+				}
+				continue
+			}
+			if strings.ToUpper(f.Name) == rest {
+				v, err := envValue(f.Type, value)
+				if err != nil {
+					return fmt.Errorf("Failed to apply %s: %v", envKey, err)
+				}
+				merged[f.Name] = v
+				origins[f.Name] = "env " + envKey
+			}
+		}
+	}
+	return nil
+}
+
+var nonAlnum = regexp.MustCompile(`[^A-Z0-9]`)
+
+// envName returns how an entry name appears in environment variable names.
+func envName(name string) string {
+	return nonAlnum.ReplaceAllString(strings.ToUpper(name), "_")
+}
+
+func fieldByEnvName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for _, f := range reflect.VisibleFields(t) { // including those of embedded structs
+		if f.IsExported() && !f.Anonymous && strings.ToUpper(f.Name) == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// envValue converts an environment variable to the TOML value for a field of type t.
+// Lists are comma-separated.
+func envValue(t reflect.Type, s string) (any, error) {
+	if t == durationType {
+		_, err := time.ParseDuration(s)
+		return s, err
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return envValue(t.Elem(), s)
+	case reflect.String:
+		return s, nil
+	case reflect.Bool:
+		return strconv.ParseBool(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(s, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(s, 10, 63)
+		return int64(v), err
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(s, 64)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.String {
+			var list []string
+			for _, item := range strings.Split(s, ",") {
+				list = append(list, strings.TrimSpace(item))
+			}
+			return list, nil
+		}
+	}
+	return nil, fmt.Errorf("%s values can't be set from the environment", t)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kk2simon/ghost-cli/llm"
+)
+
+func TestParseConfigLayers(t *testing.T) {
+	home, project := t.TempDir(), t.TempDir()
+	write := func(path, text string) {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(home, ".ghost", "config.toml"), `LogLevel = "info"
+
+[[LLMs]]
+Name = "openai"
+APIType = "openaichat"
+APIKey = "global"
+Model = "gpt-4.1"
+
+[[Mcps]]
+Name = "fs"
+Command = "npx"
+`)
+	write(filepath.Join(project, ".ghost", "config.toml"), `[[LLMs]]
+Name = "openai"
+Model = "gpt-4.1-mini"
+
+[[Mcps]]
+Name = "git"
+Command = "uvx"
+[Mcps.Sandbox]
+CPUSeconds = 10
+`)
+	t.Setenv("HOME", home)
+	t.Setenv("GHOST_CONFIG_TOML_FILE", "")
+	t.Setenv("GHOST_LLMS_OPENAI_APIKEY", "from-env")
+	t.Setenv("GHOST_MCPS_FS_CACHETTL", "1m")
+	wd, _ := os.Getwd()
+	if err := os.Chdir(project); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	cfg, err := ParseConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cfg.LLMs) != 1 || cfg.LLMs[0].Model != "gpt-4.1-mini" || cfg.LLMs[0].APIType != "openaichat" {
+		t.Errorf("LLMs not merged by name: %+v", cfg.LLMs)
+	}
+	if cfg.LLMs[0].APIKey != "from-env" {
+		t.Errorf("APIKey = %q, want the environment override", cfg.LLMs[0].APIKey)
+	}
+	if len(cfg.Mcps) != 2 || cfg.Mcps[0].CacheTTL != time.Minute || cfg.Mcps[1].Sandbox == nil || cfg.Mcps[1].Sandbox.CPUSeconds != 10 {
+		t.Errorf("Mcps not merged: %+v", cfg.Mcps)
+	}
+
+	for key, want := range map[string]string{
+		"LogLevel":                     filepath.Join(home, ".ghost", "config.toml") + ":1",
+		"LLMs[openai].Model":           filepath.Join(project, ".ghost", "config.toml") + ":3",
+		"LLMs[openai].APIKey":          "env GHOST_LLMS_OPENAI_APIKEY",
+		"Mcps[git].Sandbox.CPUSeconds": filepath.Join(project, ".ghost", "config.toml") + ":9",
+	} {
+		if got := cfg.origins[key]; got != want {
+			t.Errorf("origin of %s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	err := os.WriteFile(path, []byte(`LogLevel = "info"
+
+[[LLMs]]
+Name = "openai"
+APIType = "openai"
+APIKey = "literal"
+Modle = "gpt-4.1"
+Fallback = ["gemini"]
+
+[[Mcps]]
+Name = "fs"
+Command = "ghost-test-no-such-command"
+Required = true
+
+[[Mcps]]
+Name = "git"
+Command = "ghost-test-no-such-command"
+
+[[LLMs]]
+Name = "second"
+APIType = "openaichat"
+APIKey = "literal"
+Model = "gpt-4.1"
+Fallback = ["openai"]
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", t.TempDir())
+	cfg, err := ParseConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, d := range validateConfig(cfg) {
+		got = append(got, d.String())
+	}
+	for _, want := range []string{
+		path + `:5: error: LLM openai: invalid APIType "openai"`,
+		path + ":7: error: unknown key LLMs.Modle",
+		path + ":4: warning: LLM openai has no Model",
+		path + `:8: error: LLM openai: no fallback LLM called "gemini"`,
+		path + ":12: error: MCP fs: Command ghost-test-no-such-command can't be run",
+		path + ":17: warning: MCP git: Command ghost-test-no-such-command can't be run",
+		path + ":24: error: LLM second: fallback LLM openai has no Model",
+	} {
+		found := false
+		for _, g := range got {
+			found = found || strings.HasPrefix(g, want)
+		}
+		if !found {
+			t.Errorf("missing diagnostic %q in:\n%s", want, strings.Join(got, "\n"))
+		}
+	}
+}
+
+func TestResolveProfile(t *testing.T) {
+	seed := int64(1)
+	profiles := []Profile{
+		{Name: "base", LLM: "openai", Model: "gpt-4.1", Approval: "ask", GenerationParams: llm.GenerationParams{Temperature: new(float64), Seed: &seed}},
+		{Name: "review", Inherits: "base", Model: "o3", Mcps: []string{"git"}, GenerationParams: llm.GenerationParams{MaxOutputTokens: 1000}},
+		{Name: "loop", Inherits: "loop2"},
+		{Name: "loop2", Inherits: "loop"},
+	}
+	p, err := resolveProfile(profiles, "review")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.LLM != "openai" || p.Model != "o3" || p.Approval != "ask" || len(p.Mcps) != 1 ||
+		p.Temperature == nil || p.Seed == nil || p.MaxOutputTokens != 1000 {
+		t.Errorf("review = %+v", p)
+	}
+	if _, err := resolveProfile(profiles, "loop"); err == nil {
+		t.Error("inheritance cycle not detected")
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kk2simon/ghost-cli/base"
+	"github.com/kk2simon/ghost-cli/llm"
+	"github.com/kk2simon/ghost-cli/tools"
+
+	"github.com/BurntSushi/toml"
+)
+
+// diagnostic is a problem found in the config.
+type diagnostic struct {
+	pos     string // file:line, or an environment variable
+	warning bool   // not an error, e.g. a key that may come from elsewhere
+	msg     string
+}
+
+func (d diagnostic) String() string {
+	severity := "error"
+	if d.warning {
+		severity = "warning"
+	}
+	return fmt.Sprintf("%s: %s: %s", d.pos, severity, d.msg)
+}
+
+var arrayIndex = regexp.MustCompile(`\[\d+\]`)
+
+// checkLayer reports unknown keys and duplicate or missing names in one config file.
+func checkLayer(file string, lines map[string]int, md toml.MetaData, layer map[string]any) []diagnostic {
+	var diags []diagnostic
+	for _, key := range md.Undecoded() {
+		// Undecoded keys have no index, report every entry using them
+		found := false
+		for indexed, line := range lines {
+			if arrayIndex.ReplaceAllString(indexed, "") == key.String() {
+				diags = append(diags, diagnostic{pos: fmt.Sprintf("%s:%d", file, line), msg: fmt.Sprintf("unknown key %s", key)})
+				found = true
+			}
+		}
+		if !found {
+			diags = append(diags, diagnostic{pos: file, msg: fmt.Sprintf("unknown key %s", key)})
+		}
+	}
+
+	for _, list := range []string{"LLMs", "Mcps", "Profiles"} {
+		entries, _ := layer[list].([]map[string]any)
+		names := map[string]bool{}
+		for i, e := range entries {
+			pos := origin(file, lines, fmt.Sprintf("%s[%d].Name", list, i))
+			name, _ := e["Name"].(string)
+			switch {
+			case name == "":
+				diags = append(diags, diagnostic{pos: pos, msg: fmt.Sprintf("%s entry without Name", list)})
+			case names[name]:
+				diags = append(diags, diagnostic{pos: pos, msg: fmt.Sprintf("duplicate %s name %q, entries are merged by Name", list, name)})
+			}
+			names[name] = true
+		}
+	}
+	return diags
+}
+
+// compareDiagnostics orders diagnostics by file and line.
+func compareDiagnostics(a, b diagnostic) int {
+	aFile, aLine := splitPos(a.pos)
+	bFile, bLine := splitPos(b.pos)
+	if c := strings.Compare(aFile, bFile); c != 0 {
+		return c
+	}
+	return aLine - bLine
+}
+
+func splitPos(pos string) (string, int) {
+	i := strings.LastIndexByte(pos, ':')
+	if i < 0 {
+		return pos, 0
+	}
+	line, err := strconv.Atoi(pos[i+1:])
+	if err != nil {
+		return pos, 0
+	}
+	return pos[:i], line
+}
+
+// llmAPITypes are the APIType values llm.BuildLLMProvider supports.
+var llmAPITypes = []string{"gemini", "openaichat", "openairesponse"}
+
+// validateConfig reports mistakes that would otherwise only show when the
+// value is used, or never: unknown keys, invalid values, duplicate names,
+// missing credentials and commands that can't be found.
+func validateConfig(cfg Config) []diagnostic {
+	diags := slices.Clone(cfg.layerDiagnostics)
+	pos := func(keys ...string) string {
+		for _, key := range keys {
+			if o, ok := cfg.origins[key]; ok {
+				return o
+			}
+		}
+		return "config"
+	}
+	add := func(pos string, warning bool, format string, args ...any) {
+		diags = append(diags, diagnostic{pos: pos, warning: warning, msg: fmt.Sprintf(format, args...)})
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		add(pos("LogLevel"), false, "invalid LogLevel %q, use debug, info, warn or error", cfg.LogLevel)
+	}
+	if _, err := base.NewRedactor(cfg.RedactPatterns); err != nil {
+		add(pos("RedactPatterns"), false, "%v", err)
+	}
+
+	for i, l := range cfg.LLMs {
+		key := entryKey("LLMs", l.Name, i)
+		at := func(field string) string { return pos(key+"."+field, key+".Name") }
+
+		if !slices.Contains(llmAPITypes, l.APIType) {
+			add(at("APIType"), false, "LLM %s: invalid APIType %q, use one of %s", l.Name, l.APIType, strings.Join(llmAPITypes, ", "))
+		}
+		if l.Model == "" {
+			add(at("Model"), true, "LLM %s has no Model, it must be given with -m", l.Name)
+		}
+
+		var keyFields []string
+		for field, value := range map[string]string{"APIKey": l.APIKey, "APIKeyFile": l.APIKeyFile, "APIKeyCommand": l.APIKeyCommand} {
+			if value != "" {
+				keyFields = append(keyFields, field)
+			}
+		}
+		if len(l.APIKeys) > 0 {
+			keyFields = append(keyFields, "APIKeys")
+		}
+		slices.Sort(keyFields)
+		switch {
+		case len(keyFields) > 1:
+			add(at(keyFields[0]), false, "LLM %s: set only one of %s", l.Name, strings.Join(keyFields, ", "))
+		case len(keyFields) == 0:
+			if !sdkKeyInEnv(l.APIType) && l.Backend != "vertex" && (l.Azure == nil || l.Azure.ADToken == "") {
+				add(at("Name"), true, "LLM %s has no APIKey, APIKeyFile, APIKeyCommand or APIKeys", l.Name)
+			}
+		case l.APIKeyFile != "":
+			if _, err := os.Stat(l.APIKeyFile); err != nil {
+				add(at("APIKeyFile"), false, "LLM %s: %v", l.Name, err)
+			}
+		case l.APIKeyCommand != "":
+			checkSecretRef(add, at("APIKeyCommand"), "LLM "+l.Name, "cmd:"+l.APIKeyCommand)
+		case len(l.APIKeys) > 0:
+			for _, ref := range l.APIKeys {
+				checkSecretRef(add, at("APIKeys"), "LLM "+l.Name, ref)
+			}
+		default:
+			checkSecretRef(add, at("APIKey"), "LLM "+l.Name, l.APIKey)
+		}
+		if err := l.GenerationParams.Validate(); err != nil {
+			add(at("Name"), false, "LLM %s: %v", l.Name, err)
+		}
+		switch {
+		case l.Backend == "" || l.Backend == "gemini":
+		case l.APIType != "gemini":
+			add(at("Backend"), false, "LLM %s: Backend is only supported by the gemini APIType", l.Name)
+		case l.Backend != "vertex":
+			add(at("Backend"), false, "LLM %s: invalid Backend %q, use gemini or vertex", l.Name, l.Backend)
+		case l.Project == "" || l.Location == "":
+			add(at("Backend"), false, "LLM %s: the vertex Backend needs Project and Location", l.Name)
+		}
+		if a := l.Azure; a != nil {
+			switch {
+			case l.APIType != "openaichat" && l.APIType != "openairesponse":
+				add(at("Azure"), false, "LLM %s: Azure is only supported by the openaichat and openairesponse APITypes", l.Name)
+			case a.Endpoint == "" || a.APIVersion == "":
+				add(at("Azure"), false, "LLM %s: Azure needs Endpoint and APIVersion", l.Name)
+			case l.Host != "":
+				add(at("Host"), true, "LLM %s: Host is not used with Azure, Azure.Endpoint is", l.Name)
+			}
+			checkSecretRef(add, pos(key+".Azure.ADToken", key+".Azure", key+".Name"), "LLM "+l.Name+" Azure ADToken", a.ADToken)
+		}
+		if l.CredentialsFile != "" {
+			if _, err := os.Stat(l.CredentialsFile); err != nil {
+				add(at("CredentialsFile"), false, "LLM %s: %v", l.Name, err)
+			}
+		}
+		if l.Proxy != "" {
+			if u, err := url.Parse(l.Proxy); err != nil || u.Host == "" {
+				add(at("Proxy"), false, "LLM %s: Proxy %q is not a URL like http://proxy:3128", l.Name, l.Proxy)
+			}
+		}
+		if l.CACertFile != "" {
+			if _, err := os.Stat(l.CACertFile); err != nil {
+				add(at("CACertFile"), false, "LLM %s: %v", l.Name, err)
+			}
+		}
+		for name, value := range l.Headers {
+			checkSecretRef(add, pos(key+".Headers."+name, key+".Headers", key+".Name"), fmt.Sprintf("LLM %s header %s", l.Name, name), value)
+		}
+		if l.InsecureSkipVerify {
+			add(at("InsecureSkipVerify"), true, "LLM %s does not verify TLS certificates", l.Name)
+		}
+		for _, name := range l.Fallback {
+			i := slices.IndexFunc(cfg.LLMs, func(other llm.LLMConfig) bool { return other.Name == name })
+			switch {
+			case name == l.Name:
+				add(at("Fallback"), false, "LLM %s falls back to itself", l.Name)
+			case i < 0:
+				add(at("Fallback"), false, "LLM %s: no fallback LLM called %q", l.Name, name)
+			case cfg.LLMs[i].Model == "":
+				// the chat continues with the fallback's own Model
+				add(at("Fallback"), false, "LLM %s: fallback LLM %s has no Model", l.Name, name)
+			}
+		}
+	}
+
+	for i, m := range cfg.Mcps {
+		key := entryKey("Mcps", m.Name, i)
+		at := func(field string) string { return pos(key+"."+field, key+".Name") }
+
+		if m.Command == "" {
+			add(at("Name"), false, "MCP %s has no Command", m.Name)
+		} else if _, err := m.CommandPath(); err != nil {
+			// ghost starts without servers that aren't required
+			add(at("Command"), !m.Required, "MCP %s: Command %s can't be run: %v", m.Name, m.Command, err)
+		}
+		for _, kv := range m.Env {
+			name, value, ok := strings.Cut(kv, "=")
+			if !ok {
+				add(at("Env"), false, "MCP %s: Env entry %q is not KEY=VALUE", m.Name, name)
+				continue
+			}
+			checkSecretRef(add, at("Env"), fmt.Sprintf("MCP %s Env %s", m.Name, name), value)
+		}
+		if m.Sandbox != nil {
+			if runtime.GOOS != "linux" {
+				add(at("Sandbox"), false, "MCP %s: Sandbox is only supported on Linux", m.Name)
+			} else if _, err := exec.LookPath("bwrap"); err != nil && m.Sandbox.Isolate {
+				add(pos(key+".Sandbox.Isolate", key+".Name"), false, "MCP %s: Sandbox Isolate needs bubblewrap (bwrap)", m.Name)
+			}
+		}
+	}
+	for i, p := range cfg.Profiles {
+		key := entryKey("Profiles", p.Name, i)
+		at := func(field string) string { return pos(key+"."+field, key+".Name") }
+
+		if _, err := resolveProfile(cfg.Profiles, p.Name); err != nil {
+			add(at("Inherits"), false, "%v", err)
+		}
+		if p.LLM != "" && !slices.ContainsFunc(cfg.LLMs, func(l llm.LLMConfig) bool { return l.Name == p.LLM }) {
+			add(at("LLM"), false, "profile %s: no LLM called %q", p.Name, p.LLM)
+		}
+		for _, name := range p.Mcps {
+			if !slices.ContainsFunc(cfg.Mcps, func(m tools.McpConfig) bool { return m.Name == name }) {
+				add(at("Mcps"), false, "profile %s: no MCP called %q", p.Name, name)
+			}
+		}
+		if err := p.GenerationParams.Validate(); err != nil {
+			add(at("Name"), false, "profile %s: %v", p.Name, err)
+		}
+		if !slices.Contains(approvalPolicies, p.Approval) {
+			add(at("Approval"), false, "profile %s: invalid Approval %q, use ask, auto or deny", p.Name, p.Approval)
+		}
+	}
+
+	slices.SortStableFunc(diags, compareDiagnostics)
+	return diags
+}
+
+// checkSecretRef reports references of base.ResolveSecret that can't resolve,
+// without running commands. They are warnings, since they may resolve where
+// the LLM or MCP is used, and are only resolved when it is.
+func checkSecretRef(add func(pos string, warning bool, format string, args ...any), pos, what, ref string) {
+	if !base.IsSecretRef(ref) {
+		return
+	}
+	kind, arg, _ := strings.Cut(ref, ":")
+	switch kind {
+	case "env":
+		if _, ok := os.LookupEnv(arg); !ok {
+			add(pos, true, "%s: environment variable %s is not set", what, arg)
+		}
+	case "file":
+		if _, err := os.Stat(arg); err != nil {
+			add(pos, true, "%s: %v", what, err)
+		}
+	case "cmd":
+		if program := strings.Fields(arg); len(program) > 0 {
+			if _, err := exec.LookPath(program[0]); err != nil {
+				add(pos, true, "%s: command %s can't be run: %v", what, program[0], err)
+			}
+		}
+	}
+}
+
+// sdkKeyInEnv reports whether the SDK of apiType finds an API key in its own environment variables.
+func sdkKeyInEnv(apiType string) bool {
+	switch apiType {
+	case "gemini":
+		return os.Getenv("GEMINI_API_KEY") != "" || os.Getenv("GOOGLE_API_KEY") != ""
+	case "openaichat", "openairesponse":
+		return os.Getenv("OPENAI_API_KEY") != ""
+	}
+	return false
+}
@@ -3,8 +3,11 @@ package main
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"strings"
 
+	"github.com/fatih/color"
 	"github.com/kk2simon/ghost-cli/base"
 	"github.com/kk2simon/ghost-cli/cli"
 	"github.com/kk2simon/ghost-cli/llm"
@@ -12,34 +15,125 @@ import (
 )
 
 func main() {
+	tools.RunSandboxShim()
+
+	// run returns instead of exiting, so its deferred cleanup, like closing
+	// the log and reporting redactions, runs before ghost exits
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
 	ctx := context.Background()
 	appFlags := cli.ParseFlags()
+
+	// MCP servers on stdio speak the protocol on stdout, and --json prints
+	// only JSON there, so move all other output to stderr
+	mcpOut := os.Stdout
+	if appFlags.Command == "mcp serve" || (appFlags.Command == "mcp gateway" && appFlags.HTTPAddr == "") || appFlags.JSON {
+		os.Stdout = os.Stderr
+		color.Output = os.Stderr
+	}
+
+	if appFlags.Command == "sessions ls" {
+		return wrapErr(listSessions(os.Stdout), "Failed to list sessions")
+	}
+
 	cfg, err := ParseConfig(appFlags.CfgPath)
-	exitIfErr(err, "Failed to parse config")
+	if err != nil {
+		return wrapErr(err, "Failed to parse config")
+	}
+
+	if appFlags.Command == "config show" || appFlags.Command == "config validate" {
+		err := runConfig(cfg, appFlags.Command, appFlags.Origin, os.Stdout)
+		return wrapErr(err, "Failed to run config command")
+	}
+	configErrs := 0
+	for _, d := range validateConfig(cfg) {
+		if d.warning {
+			color.Yellow("Config %s", d)
+		} else {
+			color.Red("Config %s", d)
+			configErrs++
+		}
+	}
+	if configErrs > 0 {
+		return fmt.Errorf("%d error(s) in config, see ghost config validate", configErrs)
+	}
+	allMcps := cfg.Mcps // ask_ghost profiles choose from all of them
+	profile, err := applyProfile(&cfg, appFlags)
+	if err != nil {
+		return wrapErr(err, "Failed to apply profile")
+	}
 
-	logger, closeLogger, err := base.InitLogger(cfg.LogPath, cfg.LogLevel)
-	exitIfErr(err, "Failed to initialize logger")
+	redactor, err := base.NewRedactor(cfg.RedactPatterns)
+	if err != nil {
+		return wrapErr(err, "Failed to parse config")
+	}
+
+	logger, closeLogger, err := base.InitLogger(cfg.LogPath, cfg.LogLevel, redactor)
+	if err != nil {
+		return wrapErr(err, "Failed to initialize logger")
+	}
 	defer closeLogger()
+	defer reportRedactions(redactor, logger)
+
+	switch appFlags.Command {
+	case "chat", "run":
+		err := runChat(ctx, cfg, appFlags, profile, logger, redactor)
+		return wrapErr(err, "Error during chat")
+	case "tools list":
+		err := listTools(ctx, cfg, appFlags, logger, mcpOut)
+		return wrapErr(err, "Failed to list tools")
+	case "tools inspect":
+		err := inspectTool(ctx, cfg, appFlags, logger, mcpOut)
+		return wrapErr(err, "Failed to inspect tool")
+	case "mcp ping":
+		err := pingMCPs(ctx, cfg, appFlags.Args, logger, os.Stdout)
+		return wrapErr(err, "MCP ping failed")
+	case "mcp serve":
+		err := serveMCP(ctx, cfg, allMcps, appFlags, profile, logger, redactor, os.Stdin, mcpOut)
+		return wrapErr(err, "Failed to serve MCP")
+	case "mcp gateway":
+		err := serveGateway(ctx, cfg, appFlags, profile, logger, redactor, os.Stdin, mcpOut)
+		return wrapErr(err, "Failed to serve MCP gateway")
+	default:
+		return fmt.Errorf("unknown command %q", appFlags.Command)
+	}
+}
 
-	logger.Info("Read prompt file", "path", appFlags.PromptFile)
+// runChat runs "ghost chat", or "ghost run", which sends one prompt and
+// prints the reply. The prompt comes from the arguments of run or the prompt file.
+func runChat(ctx context.Context, cfg Config, appFlags *cli.Flags, profile Profile,
+	logger *slog.Logger, redactor *base.Redactor) error {
 
-	prompt, err := cli.ParsePromptFile(appFlags.PromptFile)
-	exitIfErr(err, "Failed to read prompt")
+	var prompt string
+	if appFlags.Command == "run" && len(appFlags.Args) > 0 {
+		prompt = strings.Join(appFlags.Args, " ")
+	} else {
+		logger.Info("Read prompt file", "path", appFlags.PromptFile)
+		var err error
+		if prompt, err = cli.ParsePromptFile(appFlags.PromptFile); err != nil {
+			return fmt.Errorf("Failed to read prompt: %v", err)
+		}
+	}
+	prompt = redactor.Redact(prompt)
 	logger.Debug("prompt got", "prompt", prompt)
 
 	toolsRuntime, err := tools.InitializeMCP(ctx, cfg.Mcps, logger)
-	exitIfErr(err, "Failed to initialize MCP")
+	if err != nil {
+		return fmt.Errorf("Failed to initialize MCP: %v", err)
+	}
 	defer toolsRuntime.CloseFunc()
+	toolsRuntime.Redactor = redactor
+	toolsRuntime.MaxToolFailures = cfg.MaxToolFailures
+	toolsRuntime.Confirm = confirmFor(profile.Approval)
 
-	var llmCfg llm.LLMConfig
-	for _, c := range cfg.LLMs {
-		if c.Name == appFlags.LLMName || appFlags.LLMName == "" {
-			llmCfg = c
-			break
-		}
-	}
-	if llmCfg.APIType == "" {
-		exitIfErr(fmt.Errorf("no LLM configuration found"), "")
+	llmCfg, err := selectLLM(cfg.LLMs, appFlags.LLMName)
+	if err != nil {
+		return err
 	}
 
 	modelToUse := llmCfg.Model
@@ -47,19 +141,69 @@ func main() {
 		modelToUse = appFlags.ModelName
 	}
 
-	llmProvider, err := llm.BuildLLMProvider(ctx, llmCfg, logger)
-	exitIfErr(err, "Failed to build LLM")
+	llmProvider, err := llm.BuildLLMChain(ctx, cfg.LLMs, llmCfg, logger)
+	if err != nil {
+		return fmt.Errorf("Failed to build LLM: %v", err)
+	}
+
+	interact := llm.SingleTurn
+	if appFlags.Command == "chat" {
+		interact = func(reply string) (string, error) {
+			userInput, err := llm.ConsoleInteract(reply)
+			return redactor.Redact(userInput), err
+		}
+	}
+	sess := newSession(appFlags.Command, profile.Name, llmCfg.Name, modelToUse, prompt)
+	defer func() {
+		if err := sess.save(); err != nil {
+			logger.Warn("Failed to save session", "error", err)
+		}
+	}()
 
-	resp, err := llmProvider.Chat(ctx, llm.Prompt{User: prompt}, modelToUse,
-		toolsRuntime.Tools, toolsRuntime.Caller)
-	exitIfErr(err, "Error during chat")
+	resp, err := llmProvider.Chat(ctx, llm.Prompt{User: prompt}, modelToUse, toolsRuntime, sess.record(interact))
+	if err != nil {
+		return err
+	}
 	logger.Info("Chat done", "Result", resp)
+	if appFlags.Command == "run" {
+		fmt.Println(resp)
+	}
+	return nil
+}
 
+// selectLLM returns the LLM config called name, or the first one if name is empty.
+func selectLLM(cfgs []llm.LLMConfig, name string) (llm.LLMConfig, error) {
+	names := make([]string, 0, len(cfgs))
+	for _, c := range cfgs {
+		if name == "" {
+			if len(cfgs) > 1 {
+				color.Yellow("No LLM given with -l, using the first one: %s", c.Name)
+			}
+			return c, nil
+		}
+		if c.Name == name {
+			return c, nil
+		}
+		names = append(names, c.Name)
+	}
+	if len(cfgs) == 0 {
+		return llm.LLMConfig{}, fmt.Errorf("no LLM configuration found")
+	}
+	return llm.LLMConfig{}, fmt.Errorf("no LLM called %q, configured: %s", name, strings.Join(names, ", "))
+}
+
+// reportRedactions tells the user how many secrets were kept from leaving the process.
+func reportRedactions(redactor *base.Redactor, logger *slog.Logger) {
+	if summary := redactor.Summary(); summary != "" {
+		logger.Info(summary)
+		color.Yellow(summary)
+	}
 }
 
-func exitIfErr(err error, msg string) {
+// wrapErr prefixes err, if any, with msg for the user.
+func wrapErr(err error, msg string) error {
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %s: %v\\n", msg, err)
-		os.Exit(1)
+		return fmt.Errorf("%s: %w", msg, err)
 	}
+	return nil
 }
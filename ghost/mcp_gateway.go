@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/kk2simon/ghost-cli/base"
+	"github.com/kk2simon/ghost-cli/cli"
+	"github.com/kk2simon/ghost-cli/tools"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// serveGateway starts all configured MCP servers and offers them as one MCP server,
+// on stdio or, with -http, over HTTP (SSE). On stdio nobody can confirm tool calls,
+// so only auto-approved tools run; over HTTP ghost asks on its console, or
+// follows the Approval of the profile.
+func serveGateway(ctx context.Context, cfg Config, appFlags *cli.Flags, profile Profile, logger *slog.Logger,
+	redactor *base.Redactor, stdin io.Reader, stdout io.Writer) error {
+
+	toolsRuntime, err := tools.InitializeMCP(ctx, cfg.Mcps, logger)
+	if err != nil {
+		return fmt.Errorf("Failed to initialize MCP: %v", err)
+	}
+	defer toolsRuntime.CloseFunc()
+	toolsRuntime.Redactor = redactor
+
+	s := tools.NewGateway(ctx, toolsRuntime)
+
+	if appFlags.HTTPAddr != "" {
+		toolsRuntime.Confirm = confirmFor(profile.Approval)
+		logger.Info("Serving MCP gateway over HTTP", "addr", appFlags.HTTPAddr)
+		fmt.Printf("MCP gateway listening on %s (SSE endpoint /sse)\n", appFlags.HTTPAddr)
+		return server.NewSSEServer(s).Start(appFlags.HTTPAddr)
+	}
+
+	toolsRuntime.Confirm = tools.DenyConfirm
+	logger.Info("Serving MCP gateway on stdio")
+	return server.NewStdioServer(s).Listen(ctx, stdin, stdout)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/kk2simon/ghost-cli/base"
+	"github.com/kk2simon/ghost-cli/cli"
+	"github.com/kk2simon/ghost-cli/llm"
+	"github.com/kk2simon/ghost-cli/tools"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// serveMCP runs ghost as a stdio MCP server with a single ask_ghost tool,
+// which runs the whole LLM and tool loop for a prompt and returns the final answer.
+// A profile given to ask_ghost picks the LLM, model, generation params and MCP
+// servers, of allMcps, otherwise those of the --profile ghost was started with
+// apply. Nobody can confirm tool calls here, so only auto-approved tools run,
+// or every tool under Approval "auto".
+func serveMCP(ctx context.Context, cfg Config, allMcps []tools.McpConfig, appFlags *cli.Flags, profile Profile,
+	logger *slog.Logger, redactor *base.Redactor, stdin io.Reader, stdout io.Writer) error {
+
+	newRuntime := func(mcps []tools.McpConfig, approval string) (*tools.Runtime, error) {
+		toolsRuntime, err := tools.InitializeMCP(ctx, mcps, logger)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to initialize MCP: %v", err)
+		}
+		toolsRuntime.Redactor = redactor
+		toolsRuntime.MaxToolFailures = cfg.MaxToolFailures
+		toolsRuntime.Confirm = tools.DenyConfirm
+		if approval == "auto" {
+			toolsRuntime.Confirm = tools.ApproveConfirm
+		}
+		return toolsRuntime, nil
+	}
+
+	toolsRuntime, err := newRuntime(cfg.Mcps, profile.Approval)
+	if err != nil {
+		return err
+	}
+	// runtimes of the profiles given to ask_ghost, started on first use
+	var runtimesMu sync.Mutex
+	runtimes := map[string]*tools.Runtime{profile.Name: toolsRuntime}
+	defer func() {
+		runtimesMu.Lock()
+		defer runtimesMu.Unlock()
+		for _, rt := range runtimes {
+			rt.CloseFunc()
+		}
+	}()
+	runtimeFor := func(p Profile) (*tools.Runtime, error) {
+		runtimesMu.Lock()
+		defer runtimesMu.Unlock()
+		if rt, ok := runtimes[p.Name]; ok {
+			return rt, nil
+		}
+		if !slices.Contains(approvalPolicies, p.Approval) {
+			return nil, fmt.Errorf("profile %s: invalid Approval %q, use ask, auto or deny", p.Name, p.Approval)
+		}
+		mcps, err := profileMcps(allMcps, p)
+		if err != nil {
+			return nil, err
+		}
+		rt, err := newRuntime(mcps, p.Approval)
+		if err != nil {
+			return nil, err
+		}
+		runtimes[p.Name] = rt
+		return rt, nil
+	}
+
+	askGhost := mcp.NewTool("ask_ghost",
+		mcp.WithDescription("Delegate a task to the ghost agent. It works on the prompt with its LLM and MCP tools and returns the final answer."),
+		mcp.WithString("prompt", mcp.Required(), mcp.Description("The task or question for the agent")),
+		mcp.WithString("profile", mcp.Description("Name of the configured profile or LLM to use, defaults to the one ghost was started with")),
+	)
+
+	s := server.NewMCPServer("ghost", "1.0.0", server.WithToolCapabilities(false))
+	s.AddTool(askGhost, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		prompt, _ := request.Params.Arguments["prompt"].(string)
+		if prompt == "" {
+			return mcp.NewToolResultError("prompt is required"), nil
+		}
+		prompt = redactor.Redact(prompt)
+		llmName, modelName := appFlags.LLMName, appFlags.ModelName
+		llms := cfg.LLMs
+		rt := toolsRuntime
+		name, _ := request.Params.Arguments["profile"].(string)
+		if name != "" && slices.ContainsFunc(cfg.Profiles, func(p Profile) bool { return p.Name == name }) {
+			p, err := resolveProfile(cfg.Profiles, name)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			if rt, err = runtimeFor(p); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			llmName, modelName = p.LLM, p.Model
+			llms = withParams(llms, p.GenerationParams.Override(flagParams(appFlags)))
+		} else if name != "" {
+			llmName, modelName = name, "" // an LLM name
+		}
+
+		llmCfg, err := selectLLM(llms, llmName)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		modelToUse := llmCfg.Model
+		if modelName != "" {
+			modelToUse = modelName
+		}
+
+		llmProvider, err := llm.BuildLLMChain(ctx, llms, llmCfg, logger)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Failed to build LLM", err), nil
+		}
+
+		logger.Info("ask_ghost", "llm", llmCfg.Name, "model", modelToUse)
+		answer, err := llmProvider.Chat(ctx, llm.Prompt{User: prompt}, modelToUse, rt, llm.SingleTurn)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Error during chat", err), nil
+		}
+		return mcp.NewToolResultText(answer), nil
+	})
+
+	logger.Info("Serving MCP on stdio")
+	return server.NewStdioServer(s).Listen(ctx, stdin, stdout)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/kk2simon/ghost-cli/base"
+	"github.com/kk2simon/ghost-cli/cli"
+	"github.com/kk2simon/ghost-cli/llm"
+)
+
+func TestAskGhostProfileErrors(t *testing.T) {
+	cfg := Config{
+		LLMs: []llm.LLMConfig{{Name: "openai", APIType: "openaichat", APIKey: "x", Model: "m"}},
+		Profiles: []Profile{
+			{Name: "loop", Inherits: "loop2"},
+			{Name: "loop2", Inherits: "loop"},
+			{Name: "git", LLM: "openai", Mcps: []string{"git"}},
+		},
+	}
+	var requests strings.Builder
+	requests.WriteString(`{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}` + "\n")
+	for i, profile := range []string{"loop", "git"} {
+		b, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": i + 1, "method": "tools/call",
+			"params": map[string]any{"name": "ask_ghost", "arguments": map[string]any{"prompt": "hi", "profile": profile}}})
+		requests.Write(append(b, '\n'))
+	}
+
+	var out strings.Builder
+	redactor, _ := base.NewRedactor(nil)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	err := serveMCP(context.Background(), cfg, nil, &cli.Flags{}, Profile{}, logger, redactor, strings.NewReader(requests.String()), &out)
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"profile loop inherits from itself", `profile git: no MCP called \"git\"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
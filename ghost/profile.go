@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/kk2simon/ghost-cli/cli"
+	"github.com/kk2simon/ghost-cli/llm"
+	"github.com/kk2simon/ghost-cli/tools"
+)
+
+// Profile bundles the settings of a kind of session, e.g. "review" or
+// "coding", selected with --profile. Command-line flags override it.
+type Profile struct {
+	Name string
+	// Inherits names a profile whose settings apply where this one sets none.
+	Inherits string
+
+	// LLM names the LLMs entry to use, Model overrides its model.
+	LLM   string
+	Model string
+	// Mcps lists the MCP servers to start, all if empty.
+	Mcps []string
+	// PromptFile is the prompt template used when -p is not given.
+	PromptFile string
+	// Approval is "ask" to confirm tool calls on the console (the default),
+	// "auto" to run every tool without asking, or "deny" to run only the
+	// AutoApprove tools of each MCP.
+	Approval string
+	// GenerationParams override those of every LLM.
+	llm.GenerationParams
+}
+
+var approvalPolicies = []string{"", "ask", "auto", "deny"}
+
+// resolveProfile returns the profile called name with the settings it inherits.
+func resolveProfile(profiles []Profile, name string) (Profile, error) {
+	byName := func(name string) (Profile, bool) {
+		i := slices.IndexFunc(profiles, func(p Profile) bool { return p.Name == name })
+		if i < 0 {
+			return Profile{}, false
+		}
+		return profiles[i], true
+	}
+
+	p, ok := byName(name)
+	if !ok {
+		return Profile{}, fmt.Errorf("no profile called %q", name)
+	}
+	chain := []string{name}
+	for parent := p.Inherits; parent != ""; {
+		if slices.Contains(chain, parent) {
+			return Profile{}, fmt.Errorf("profile %s inherits from itself: %v", name, append(chain, parent))
+		}
+		chain = append(chain, parent)
+		pp, ok := byName(parent)
+		if !ok {
+			return Profile{}, fmt.Errorf("profile %s inherits from unknown profile %q", chain[len(chain)-2], parent)
+		}
+		inherit(&p, pp)
+		parent = pp.Inherits
+	}
+	return p, nil
+}
+
+// inherit sets the fields of p that are not set from parent.
+func inherit(p *Profile, parent Profile) {
+	inheritFields(reflect.ValueOf(p).Elem(), reflect.ValueOf(parent))
+}
+
+// inheritFields sets the zero fields of v from parent, field by field in embedded structs.
+func inheritFields(v, parent reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		switch {
+		case v.Type().Field(i).Anonymous:
+			inheritFields(v.Field(i), parent.Field(i))
+		case v.Field(i).IsZero():
+			v.Field(i).Set(parent.Field(i))
+		}
+	}
+}
+
+// applyProfile applies the profile chosen with --profile: it fills the flags not
+// given on the command line and keeps only the profile's MCP servers.
+func applyProfile(cfg *Config, appFlags *cli.Flags) (Profile, error) {
+	var p Profile
+	if appFlags.Profile != "" {
+		var err error
+		if p, err = resolveProfile(cfg.Profiles, appFlags.Profile); err != nil {
+			return p, err
+		}
+	}
+	if !slices.Contains(approvalPolicies, p.Approval) {
+		return p, fmt.Errorf("profile %s: invalid Approval %q, use ask, auto or deny", p.Name, p.Approval)
+	}
+
+	if appFlags.LLMName == "" {
+		appFlags.LLMName = p.LLM
+	}
+	if appFlags.ModelName == "" && appFlags.LLMName == p.LLM {
+		appFlags.ModelName = p.Model // the model is meant for the profile's LLM
+	}
+	if appFlags.PromptFile == "" {
+		appFlags.PromptFile = p.PromptFile
+	}
+	if appFlags.PromptFile == "" {
+		appFlags.PromptFile = "prompt.md"
+	}
+
+	cfg.LLMs = withParams(cfg.LLMs, p.GenerationParams.Override(flagParams(appFlags)))
+
+	mcps, err := profileMcps(cfg.Mcps, p)
+	if err != nil {
+		return p, err
+	}
+	cfg.Mcps = mcps
+	return p, nil
+}
+
+// profileMcps returns the MCP servers of profile p, all if it names none.
+func profileMcps(mcps []tools.McpConfig, p Profile) ([]tools.McpConfig, error) {
+	if len(p.Mcps) == 0 {
+		return mcps, nil
+	}
+	var out []tools.McpConfig
+	for _, name := range p.Mcps {
+		i := slices.IndexFunc(mcps, func(m tools.McpConfig) bool { return m.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("profile %s: no MCP called %q", p.Name, name)
+		}
+		out = append(out, mcps[i])
+	}
+	return out, nil
+}
+
+// flagParams returns the generation params given on the command line.
+func flagParams(appFlags *cli.Flags) llm.GenerationParams {
+	return llm.GenerationParams{
+		Temperature:     appFlags.Temperature,
+		TopP:            appFlags.TopP,
+		MaxOutputTokens: appFlags.MaxOutputTokens,
+		Seed:            appFlags.Seed,
+		StopSequences:   appFlags.StopSequences,
+		ReasoningEffort: appFlags.ReasoningEffort,
+		ThinkingBudget:  appFlags.ThinkingBudget,
+	}
+}
+
+// withParams returns the LLM configs with params overriding their own, fallbacks included.
+func withParams(llms []llm.LLMConfig, params llm.GenerationParams) []llm.LLMConfig {
+	out := slices.Clone(llms)
+	for i := range out {
+		out[i].GenerationParams = out[i].GenerationParams.Override(params)
+	}
+	return out
+}
+
+// confirmFor returns how tool calls are confirmed under an Approval policy.
+func confirmFor(approval string) tools.ConfirmFunc {
+	switch approval {
+	case "auto":
+		return tools.ApproveConfirm
+	case "deny":
+		return tools.DenyConfirm
+	default:
+		return tools.ConsoleConfirm
+	}
+}
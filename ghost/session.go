@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kk2simon/ghost-cli/base"
+	"github.com/kk2simon/ghost-cli/llm"
+)
+
+// session is the transcript of a chat or run, saved when it ends so that
+// "ghost sessions ls" can list it. Tool calls are not recorded.
+type session struct {
+	ID       string
+	Command  string
+	Started  time.Time
+	Profile  string `json:",omitempty"`
+	LLM      string
+	Model    string
+	Messages []llm.Message
+}
+
+// sessionsDir returns where sessions are saved.
+func sessionsDir() (string, error) {
+	stateDir, err := base.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, "sessions"), nil
+}
+
+func newSession(command, profile, llmName, model, prompt string) *session {
+	started := time.Now()
+	return &session{
+		ID:       started.Format("20060102-150405.000"),
+		Command:  command,
+		Started:  started,
+		Profile:  profile,
+		LLM:      llmName,
+		Model:    model,
+		Messages: []llm.Message{{Role: "user", Text: prompt}},
+	}
+}
+
+// record wraps interact so that replies and user messages are added to the session.
+func (s *session) record(interact llm.Interact) llm.Interact {
+	return func(reply string) (string, error) {
+		s.Messages = append(s.Messages, llm.Message{Role: "assistant", Text: reply})
+		userInput, err := interact(reply)
+		if userInput != "" {
+			s.Messages = append(s.Messages, llm.Message{Role: "user", Text: userInput})
+		}
+		return userInput, err
+	}
+}
+
+// save writes the session to the sessions directory, readable only by the user.
+func (s *session) save() error {
+	dir, err := sessionsDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create sessions directory: %w", err)
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, s.ID+".json"), b, 0o600)
+}
+
+// listSessions prints the saved sessions, newest first.
+func listSessions(w io.Writer) error {
+	dir, err := sessionsDir()
+	if err != nil {
+		return err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		fmt.Fprintf(w, "No sessions saved in %s\n", dir)
+		return nil
+	}
+	slices.Reverse(files) // IDs sort by start time
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCOMMAND\tLLM\tMODEL\tMESSAGES\tPROMPT")
+	for _, file := range files {
+		var s session
+		b, err := os.ReadFile(file)
+		if err == nil {
+			err = json.Unmarshal(b, &s)
+		}
+		if err != nil {
+			fmt.Fprintf(tw, "%s\t\t\t\t\terror: %v\n", strings.TrimSuffix(filepath.Base(file), ".json"), err)
+			continue
+		}
+		prompt := ""
+		if len(s.Messages) > 0 {
+			prompt = firstLine(s.Messages[0].Text, 60)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n", s.ID, s.Command, s.LLM, s.Model, len(s.Messages), prompt)
+	}
+	return tw.Flush()
+}
+
+// firstLine returns the first non-empty line of s, cut to max runes.
+func firstLine(s string, max int) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if r := []rune(line); len(r) > max {
+			return string(r[:max-3]) + "..."
+		}
+		return line
+	}
+	return ""
+}
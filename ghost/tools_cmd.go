@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kk2simon/ghost-cli/cli"
+	"github.com/kk2simon/ghost-cli/llm"
+	"github.com/kk2simon/ghost-cli/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// toolInfo is a tool as printed by "ghost tools list --json" and "ghost tools inspect".
+type toolInfo struct {
+	MCP         string               `json:"mcp"`
+	Name        string               `json:"name"`
+	Description string               `json:"description,omitempty"`
+	InputSchema any                  `json:"inputSchema"`
+	Annotations mcp.ToolAnnotation   `json:"annotations"`
+	Conversions []llm.ToolConversion `json:"conversions"`
+}
+
+func newToolInfo(st tools.ServerTool) toolInfo {
+	var schema any = st.Tool.InputSchema
+	if len(st.Tool.RawInputSchema) > 0 {
+		schema = st.Tool.RawInputSchema
+	}
+	return toolInfo{
+		MCP:         st.Server,
+		Name:        st.Tool.Name,
+		Description: st.Tool.Description,
+		InputSchema: schema,
+		Annotations: st.Tool.Annotations,
+		Conversions: llm.ConvertTool(st.Tool),
+	}
+}
+
+// serverTools starts the MCP servers and returns their tools.
+func serverTools(ctx context.Context, cfg Config, logger *slog.Logger) ([]toolInfo, error) {
+	toolsRuntime, err := tools.InitializeMCP(ctx, cfg.Mcps, logger)
+	if err != nil {
+		return nil, err
+	}
+	defer toolsRuntime.CloseFunc()
+
+	var infos []toolInfo
+	for _, st := range toolsRuntime.ServerTools() {
+		infos = append(infos, newToolInfo(st))
+	}
+	return infos, nil
+}
+
+// listTools runs "ghost tools list": it prints the tools of the MCP servers
+// and warns of those an LLM type can't be given.
+func listTools(ctx context.Context, cfg Config, appFlags *cli.Flags, logger *slog.Logger, stdout io.Writer) error {
+	infos, err := serverTools(ctx, cfg, logger)
+	if err != nil {
+		return err
+	}
+	if appFlags.JSON {
+		return printJSON(stdout, infos)
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "MCP\tTOOL\tDESCRIPTION")
+	for _, info := range infos {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", info.MCP, info.Name, firstLine(info.Description, 80))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, info := range infos {
+		for _, c := range info.Conversions {
+			if c.Error != "" {
+				fmt.Fprintf(stdout, "Warning: tool %s can't be given to %s LLMs: %s\n", info.Name, c.APIType, c.Error)
+			}
+		}
+	}
+	return nil
+}
+
+// inspectTool runs "ghost tools inspect <tool>": it prints the input schema
+// and annotations of the tool, and how each LLM type declares it.
+func inspectTool(ctx context.Context, cfg Config, appFlags *cli.Flags, logger *slog.Logger, stdout io.Writer) error {
+	name := appFlags.Args[0]
+	infos, err := serverTools(ctx, cfg, logger)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(infos, func(info toolInfo) bool { return info.Name == name })
+	if i < 0 {
+		return fmt.Errorf("no tool called %q, see ghost tools list", name)
+	}
+	info := infos[i]
+	if appFlags.JSON {
+		return printJSON(stdout, info)
+	}
+
+	fmt.Fprintf(stdout, "Tool %s of MCP %s\n", info.Name, info.MCP)
+	if info.Description != "" {
+		fmt.Fprintf(stdout, "\n%s\n", strings.TrimSpace(info.Description))
+	}
+	fmt.Fprintf(stdout, "\nAnnotations:\n")
+	if err := printJSON(stdout, info.Annotations); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "\nInput schema:\n")
+	if err := printJSON(stdout, info.InputSchema); err != nil {
+		return err
+	}
+	for _, c := range info.Conversions {
+		if c.Error != "" {
+			fmt.Fprintf(stdout, "\nAs declared to %s LLMs: can't be converted: %s\n", c.APIType, c.Error)
+			continue
+		}
+		fmt.Fprintf(stdout, "\nAs declared to %s LLMs:\n", c.APIType)
+		if err := printJSON(stdout, c.Tool); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printJSON(w io.Writer, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// pingMCPs runs "ghost mcp ping": it starts the named MCP servers, or all,
+// and checks that they answer.
+func pingMCPs(ctx context.Context, cfg Config, names []string, logger *slog.Logger, stdout io.Writer) error {
+	var mcps []tools.McpConfig
+	for _, m := range cfg.Mcps {
+		if len(names) == 0 || slices.Contains(names, m.Name) {
+			m.Required = false // report every failure instead of stopping at the first
+			m.Lazy = false
+			mcps = append(mcps, m)
+		}
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(mcps, func(m tools.McpConfig) bool { return m.Name == name }) {
+			return fmt.Errorf("no MCP called %q", name)
+		}
+	}
+
+	toolsRuntime, err := tools.InitializeMCP(ctx, mcps, logger)
+	if err != nil {
+		return err
+	}
+	defer toolsRuntime.CloseFunc()
+
+	failed := 0
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "MCP\tSTATUS\tTOOLS\tTIME")
+	for _, res := range toolsRuntime.Ping(ctx) {
+		if res.Err != nil {
+			failed++
+			fmt.Fprintf(tw, "%s\terror: %v\t\t\n", res.Name, res.Err)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\tok\t%d\t%s\n", res.Name, res.Tools, res.Latency.Round(time.Millisecond))
+	}
+	tw.Flush()
+	if failed > 0 {
+		return fmt.Errorf("%d of %d MCP(s) failed", failed, len(mcps))
+	}
+	return nil
+}
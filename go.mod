@@ -5,6 +5,7 @@ go 1.23.7
 toolchain go1.23.9
 
 require (
+	cloud.google.com/go/auth v0.16.1
 	github.com/BurntSushi/toml v1.5.0
 	github.com/fatih/color v1.18.0
 	github.com/lmittmann/tint v1.1.0
@@ -17,7 +18,6 @@ require (
 
 require (
 	cloud.google.com/go v0.120.0 // indirect
-	cloud.google.com/go/auth v0.16.1 // indirect
 	cloud.google.com/go/compute/metadata v0.6.0 // indirect
 	github.com/felixge/httpsnoop v1.0.4 // indirect
 	github.com/go-logr/logr v1.4.2 // indirect
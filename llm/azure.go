@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kk2simon/ghost-cli/base"
+	"github.com/openai/openai-go/option"
+)
+
+// AzureConfig points the openaichat and openairesponse APIs at an Azure
+// OpenAI resource. Requests are authenticated with the LLM's API key in the
+// api-key header, or with a Microsoft Entra ID token.
+type AzureConfig struct {
+	// Endpoint is the resource URL, e.g. https://my-resource.openai.azure.com
+	Endpoint string
+	// APIVersion is sent as api-version, e.g. "2024-10-21" for chat or
+	// "2025-03-01-preview" for the Responses API.
+	APIVersion string
+	// Deployment is the deployment chat requests go to, by default the model.
+	// The Responses API takes the deployment as model.
+	Deployment string
+	// ADToken is a reference to an Entra ID access token, resolved again
+	// after ADTokenTTL (default 30m), e.g.
+	// "cmd:az account get-access-token --resource https://cognitiveservices.azure.com --query accessToken -o tsv".
+	ADToken    string
+	ADTokenTTL time.Duration
+}
+
+const defaultADTokenTTL = 30 * time.Minute
+
+// azureOptions returns the client options for an Azure OpenAI resource.
+func azureOptions(cfg *AzureConfig) ([]option.RequestOption, error) {
+	if cfg.Endpoint == "" || cfg.APIVersion == "" {
+		return nil, fmt.Errorf("Azure needs Endpoint and APIVersion")
+	}
+	opts := []option.RequestOption{
+		option.WithBaseURL(strings.TrimSuffix(cfg.Endpoint, "/") + "/openai/"),
+		option.WithQueryAdd("api-version", cfg.APIVersion),
+		option.WithMiddleware(func(r *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+			if err := azureDeploymentPath(r, cfg.Deployment); err != nil {
+				return nil, err
+			}
+			return next(r)
+		}),
+	}
+	if cfg.ADToken != "" {
+		tokens := &tokenSource{ref: cfg.ADToken, ttl: cfg.ADTokenTTL}
+		opts = append(opts, option.WithMiddleware(func(r *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+			token, err := tokens.token(r.Context())
+			if err != nil {
+				return nil, fmt.Errorf("failed to get Azure AD token: %v", err)
+			}
+			r.Header.Del("Api-Key")
+			r.Header.Set("Authorization", "Bearer "+token)
+			return next(r)
+		}))
+	}
+	return opts, nil
+}
+
+// azureDeploymentPath moves chat completion requests to their deployment.
+func azureDeploymentPath(r *http.Request, deployment string) error {
+	const chatPath = "/openai/chat/completions"
+	if !strings.HasSuffix(r.URL.Path, chatPath) {
+		return nil
+	}
+	if deployment == "" {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		var req struct {
+			Model string `json:"model"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			return err
+		}
+		deployment = req.Model
+	}
+	prefix := strings.TrimSuffix(r.URL.Path, chatPath)
+	r.URL.Path = prefix + "/openai/deployments/" + url.PathEscape(deployment) + "/chat/completions"
+	r.URL.RawPath = ""
+	return nil
+}
+
+// tokenSource resolves a token reference and caches the token for a while.
+type tokenSource struct {
+	ref string
+	ttl time.Duration
+
+	mu      sync.Mutex
+	value   string
+	expires time.Time
+}
+
+func (s *tokenSource) token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.value != "" && time.Now().Before(s.expires) {
+		return s.value, nil
+	}
+	value, err := base.ResolveSecret(ctx, s.ref)
+	if err != nil {
+		return "", err
+	}
+	ttl := s.ttl
+	if ttl == 0 {
+		ttl = defaultADTokenTTL
+	}
+	s.value, s.expires = value, time.Now().Add(ttl)
+	return value, nil
+}
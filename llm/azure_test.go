@@ -0,0 +1,64 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kk2simon/ghost-cli/tools"
+)
+
+func TestAzure(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	t.Setenv("OPENAI_API_KEY", "not-for-azure")
+	t.Setenv("GHOST_TEST_AD_TOKEN", "entra-token")
+
+	for _, tc := range []struct {
+		name       string
+		cfg        LLMConfig
+		wantPath   string
+		wantHeader [2]string
+	}{
+		{
+			name:       "api key",
+			cfg:        LLMConfig{APIKey: "azure-key-1234", Azure: &AzureConfig{APIVersion: "2024-10-21"}},
+			wantPath:   "/openai/deployments/gpt-4o/chat/completions?api-version=2024-10-21",
+			wantHeader: [2]string{"Api-Key", "azure-key-1234"},
+		},
+		{
+			name:       "entra token and deployment",
+			cfg:        LLMConfig{Azure: &AzureConfig{APIVersion: "2024-10-21", Deployment: "chat", ADToken: "env:GHOST_TEST_AD_TOKEN"}},
+			wantPath:   "/openai/deployments/chat/chat/completions?api-version=2024-10-21",
+			wantHeader: [2]string{"Authorization", "Bearer entra-token"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			api := &fakeChatAPI{}
+			srv := httptest.NewServer(api)
+			defer srv.Close()
+
+			cfg := tc.cfg
+			cfg.Name, cfg.APIType, cfg.MaxRetries = "azure", "openaichat", -1
+			cfg.Azure.Endpoint = srv.URL
+			p, err := BuildLLMProvider(context.Background(), cfg, logger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := p.Chat(context.Background(), Prompt{User: "hi"}, "gpt-4o", &tools.Runtime{}, SingleTurn); err != nil {
+				t.Fatal(err)
+			}
+			if api.uris[0] != tc.wantPath {
+				t.Errorf("path = %s, want %s", api.uris[0], tc.wantPath)
+			}
+			h := api.headers[0]
+			if got := h.Get(tc.wantHeader[0]); got != tc.wantHeader[1] {
+				t.Errorf("%s = %q, want %q", tc.wantHeader[0], got, tc.wantHeader[1])
+			}
+			if tc.wantHeader[0] == "Api-Key" && h.Get("Authorization") != "" {
+				t.Errorf("Authorization = %q, want none", h.Get("Authorization"))
+			}
+		})
+	}
+}
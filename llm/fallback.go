@@ -0,0 +1,95 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/fatih/color"
+	"github.com/kk2simon/ghost-cli/tools"
+)
+
+// FallbackProvider continues a chat with the fallback LLMs of its config, in
+// order, when requests to the current one keep failing. The conversation so
+// far is carried over, so the chat goes on where it stopped.
+type FallbackProvider struct {
+	primary LLMProvider
+	cfg     LLMConfig
+	cfgs    []LLMConfig // all configured LLMs, to look the fallbacks up
+	logger  *slog.Logger
+}
+
+// BuildLLMChain builds the provider of cfg, wrapped in a FallbackProvider if
+// it names fallbacks. cfgs are all configured LLMs.
+func BuildLLMChain(ctx context.Context, cfgs []LLMConfig, cfg LLMConfig, logger *slog.Logger) (LLMProvider, error) {
+	primary, err := BuildLLMProvider(ctx, cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Fallback) == 0 {
+		return primary, nil
+	}
+	return &FallbackProvider{primary: primary, cfg: cfg, cfgs: cfgs, logger: logger}, nil
+}
+
+func (f *FallbackProvider) APIType() string { return f.primary.APIType() }
+
+func (f *FallbackProvider) Chat(ctx context.Context,
+	prompt Prompt, model string, toolsRuntime *tools.Runtime, interact Interact) (string, error) {
+
+	provider, name := f.primary, f.cfg.Name
+	fallbacks := f.cfg.Fallback
+	for {
+		reply, err := provider.Chat(ctx, prompt, model, toolsRuntime, interact)
+		var perr *ProviderError
+		if err == nil || !errors.As(err, &perr) || ctx.Err() != nil || len(fallbacks) == 0 {
+			return reply, err
+		}
+
+		next, nextCfg, rest, buildErr := f.nextProvider(ctx, fallbacks)
+		if buildErr != nil {
+			return "", fmt.Errorf("%w, and no fallback LLM could be used: %v", err, buildErr)
+		}
+		f.logger.Warn("LLM failed, continuing with fallback", "llm", name, "fallback", nextCfg.Name, "error", perr.Err)
+		color.Yellow("LLM %s failed (%v), continuing with %s", name, shortError(perr.Err), nextCfg.Name)
+
+		provider, name, fallbacks = next, nextCfg.Name, rest
+		prompt = Prompt{System: prompt.System, Developer: prompt.Developer, History: perr.History}
+		model = nextCfg.Model
+	}
+}
+
+// nextProvider builds the first of fallbacks that can be built, and returns
+// it with its config and the fallbacks after it.
+func (f *FallbackProvider) nextProvider(ctx context.Context, fallbacks []string) (LLMProvider, LLMConfig, []string, error) {
+	var errs []error
+	for i, name := range fallbacks {
+		cfg, ok := f.lookup(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("no LLM called %q", name))
+			continue
+		}
+		if cfg.Model == "" {
+			// the model of the failed LLM likely doesn't exist on another one
+			errs = append(errs, fmt.Errorf("fallback LLM %s has no Model", name))
+			continue
+		}
+		provider, err := BuildLLMProvider(ctx, cfg, f.logger)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return provider, cfg, fallbacks[i+1:], nil
+	}
+	return nil, LLMConfig{}, nil, errors.Join(errs...)
+}
+
+func (f *FallbackProvider) lookup(name string) (LLMConfig, bool) {
+	for _, cfg := range f.cfgs {
+		if cfg.Name == name {
+			return cfg, true
+		}
+	}
+	return LLMConfig{}, false
+}
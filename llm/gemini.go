@@ -4,19 +4,27 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"strings"
+	"sync"
 
-	"github.com/fatih/color"
-	"github.com/kk2simon/ghost-cli/cli"
 	"github.com/kk2simon/ghost-cli/tools"
 
+	"cloud.google.com/go/auth/credentials"
+	"cloud.google.com/go/auth/httptransport"
 	"github.com/mark3labs/mcp-go/mcp"
 	"google.golang.org/genai"
 )
 
 type GeminiLLMProvider struct {
-	client *genai.Client
+	name   string
+	cfg    genai.ClientConfig
+	params GenerationParams
+	retry  *retrier
 	logger *slog.Logger
+
+	mu      sync.Mutex
+	clients map[string]*genai.Client // by API key
 }
 
 func (g *GeminiLLMProvider) APIType() string {
@@ -24,22 +32,52 @@ func (g *GeminiLLMProvider) APIType() string {
 }
 
 func (g *GeminiLLMProvider) Chat(ctx context.Context,
-	prompt Prompt, model string, tools []mcp.Tool,
-	toolCaller tools.ToolCaller) (string, error) {
+	prompt Prompt, model string, toolsRuntime *tools.Runtime, interact Interact) (string, error) {
 
-	genaiTools, err := toolsToGoogle(tools)
-	if err != nil {
-		return "", err
+	genConfig := &genai.GenerateContentConfig{}
+	g.params.applyGemini(genConfig)
+	var toolsVersion uint64
+	refreshTools := func() error {
+		ts, version := toolsRuntime.Tools()
+		if version == toolsVersion {
+			return nil
+		}
+		genaiTools, err := toolsToGoogle(ts)
+		if err != nil {
+			return err
+		}
+		genConfig.Tools = genaiTools
+		toolsVersion = version
+		return nil
 	}
 
-	chats, err := g.client.Chats.Create(ctx, model, &genai.GenerateContentConfig{
-		Tools: genaiTools,
-	}, nil)
-
-	parts := []genai.Part{
-		{Text: prompt.User},
+	runner := &toolRunner{rt: toolsRuntime}
+	history := startHistory(prompt)
+	contents := geminiContents(history)
+	// send requests the conversation so far and returns the model's answer
+	send := func() (*genai.GenerateContentResponse, error) {
+		if err := refreshTools(); err != nil {
+			return nil, err
+		}
+		var resp *genai.GenerateContentResponse
+		err := g.retry.do(ctx, func(apiKey string) error {
+			client, err := g.client(ctx, apiKey)
+			if err != nil {
+				return err
+			}
+			resp, err = client.Models.GenerateContent(ctx, model, contents, genConfig)
+			return err
+		})
+		if err != nil {
+			return nil, &ProviderError{LLM: g.name, Err: err, History: history}
+		}
+		if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
+			contents = append(contents, resp.Candidates[0].Content)
+		}
+		return resp, nil
 	}
-	genContentResp, err := chats.SendMessage(ctx, parts...)
+
+	genContentResp, err := send()
 	if err != nil {
 		return "", err
 	}
@@ -49,30 +87,29 @@ func (g *GeminiLLMProvider) Chat(ctx context.Context,
 		if len(functionCalls) == 0 {
 			// Get the response text
 			respText := ""
-			if len(genContentResp.Candidates) > 0 && len(genContentResp.Candidates[0].Content.Parts) > 0 {
+			if len(genContentResp.Candidates) > 0 && genContentResp.Candidates[0].Content != nil &&
+				len(genContentResp.Candidates[0].Content.Parts) > 0 {
 				respText = genContentResp.Candidates[0].Content.Parts[0].Text
 			}
+			history = append(history, Message{Role: "assistant", Text: respText})
 
-			color.Cyan("LLM:" + respText)
-
-			// Prompt user for input
-			userInput, err := cli.PromptUser()
+			// Hand the reply over and get the next user input
+			userInput, err := interact(respText)
 			if err != nil {
-				return "", fmt.Errorf("error reading user input: %w", err)
+				return "", err
 			}
 
-			// If user input is empty or exit command, break the loop
-			if userInput == "" || userInput == "exit" { // Define an exit command
-				return "", nil // Return nil error to indicate successful chat end
+			// If user input is empty, break the loop
+			if userInput == "" {
+				return respText, nil // Return nil error to indicate successful chat end
 			}
 
-			// Create a new message part for user input
-			parts = []genai.Part{
-				{Text: userInput},
-			}
+			runner.userTurn()
 
 			// Send the new user message and continue the loop
-			genContentResp, err = chats.SendMessage(ctx, parts...)
+			history = append(history, Message{Role: "user", Text: userInput})
+			contents = append(contents, genai.NewContentFromText(userInput, genai.RoleUser))
+			genContentResp, err = send()
 			if err != nil {
 				return "", err
 			}
@@ -80,47 +117,182 @@ func (g *GeminiLLMProvider) Chat(ctx context.Context,
 			// Continue the loop to process the new response
 			continue
 		}
-		responses := make([]genai.Part, 0, len(functionCalls))
+
+		assistant := Message{Role: "assistant", Text: geminiText(genContentResp)}
 		for _, call := range functionCalls {
-			out, err := toolCaller(call.Name, call.Args)
+			assistant.ToolCalls = append(assistant.ToolCalls, MessageToolCall{ID: call.ID, Name: call.Name, Arguments: call.Args})
+		}
+		history = withToolCallIDs(append(history, assistant))
+		callIDs := history[len(history)-1].ToolCalls
+
+		responses := make([]*genai.Part, 0, len(functionCalls))
+		for i, call := range functionCalls {
+			text, failed, err := runner.call(ctx, call.Name, call.Args)
 			if err != nil {
 				return "", err
 			}
-			text := ""
-			if len(out.Content) > 0 {
-				if tc, ok := out.Content[0].(mcp.TextContent); ok {
-					text = tc.Text
-				}
-			}
-			responses = append(responses, genai.Part{
-				FunctionResponse: &genai.FunctionResponse{
-					ID:   call.ID,
-					Name: call.Name,
-					Response: map[string]any{
-						"output": text,
-					},
-				},
-			})
-		}
-		genContentResp, err = chats.SendMessage(ctx, responses...)
+			history = append(history, Message{Role: "tool", Text: text, ToolCallID: callIDs[i].ID, ToolName: call.Name, Failed: failed})
+			responses = append(responses, geminiFunctionResponse(call.ID, call.Name, text, failed))
+		}
+		contents = append(contents, genai.NewContentFromParts(responses, genai.RoleUser))
+		genContentResp, err = send()
 		if err != nil {
 			return "", err
 		}
 	}
+}
 
-	return "", nil
+// client returns the client using apiKey, created on first use.
+func (g *GeminiLLMProvider) client(ctx context.Context, apiKey string) (*genai.Client, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if c, ok := g.clients[apiKey]; ok {
+		return c, nil
+	}
+	cfg := g.cfg
+	cfg.APIKey = apiKey
+	c, err := genai.NewClient(ctx, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gemini client: %v", err)
+	}
+	g.clients[apiKey] = c
+	return c, nil
+}
+
+// geminiText returns the text parts of a reply next to function calls. Unlike
+// GenerateContentResponse.Text it doesn't log a warning about the calls.
+func geminiText(resp *genai.GenerateContentResponse) string {
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return ""
+	}
+	var texts []string
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if part.Text != "" && !part.Thought {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "")
+}
+
+// geminiFunctionResponse returns the part answering a function call.
+func geminiFunctionResponse(id, name, text string, failed bool) *genai.Part {
+	// Gemini expects failures under "error"
+	key := "output"
+	if failed {
+		key = "error"
+	}
+	return &genai.Part{
+		FunctionResponse: &genai.FunctionResponse{
+			ID:       id,
+			Name:     name,
+			Response: map[string]any{key: text},
+		},
+	}
+}
+
+// geminiContents converts a conversation to Gemini contents. Consecutive
+// tool results are sent together, as the answer to one model turn.
+func geminiContents(history []Message) []*genai.Content {
+	contents := make([]*genai.Content, 0, len(history))
+	for i, m := range history {
+		switch m.Role {
+		case "user":
+			contents = append(contents, genai.NewContentFromText(m.Text, genai.RoleUser))
+		case "assistant":
+			var parts []*genai.Part
+			if m.Text != "" {
+				parts = append(parts, genai.NewPartFromText(m.Text))
+			}
+			for _, call := range m.ToolCalls {
+				parts = append(parts, &genai.Part{FunctionCall: &genai.FunctionCall{ID: call.ID, Name: call.Name, Args: call.Arguments}})
+			}
+			contents = append(contents, genai.NewContentFromParts(parts, genai.RoleModel))
+		case "tool":
+			part := geminiFunctionResponse(m.ToolCallID, m.ToolName, m.Text, m.Failed)
+			if i > 0 && history[i-1].Role == "tool" {
+				last := contents[len(contents)-1]
+				last.Parts = append(last.Parts, part)
+				continue
+			}
+			contents = append(contents, genai.NewContentFromParts([]*genai.Part{part}, genai.RoleUser))
+		}
+	}
+	return contents
 }
 
 func NewGeminiLLMProvider(ctx context.Context, cfg LLMConfig, logger *slog.Logger) (*GeminiLLMProvider, error) {
-	geminiClient, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey:  cfg.APIKey,
-		Backend: genai.BackendGeminiAPI,
+	httpClient, err := cfg.httpClient()
+	if err != nil {
+		return nil, fmt.Errorf("LLM %s: %v", cfg.Name, err)
+	}
+	headers := make(http.Header, len(cfg.Headers))
+	for name, value := range cfg.Headers {
+		headers.Set(name, value)
+	}
+	clientCfg := genai.ClientConfig{
+		Backend:     genai.BackendGeminiAPI,
+		HTTPClient:  httpClient,
+		HTTPOptions: genai.HTTPOptions{BaseURL: cfg.Host, Headers: headers},
+	}
+	switch cfg.Backend {
+	case "", "gemini":
+	case "vertex":
+		if err := vertexConfig(ctx, cfg, &clientCfg); err != nil {
+			return nil, fmt.Errorf("LLM %s: %v", cfg.Name, err)
+		}
+	default:
+		return nil, fmt.Errorf("LLM %s: unsupported Backend %q, use gemini or vertex", cfg.Name, cfg.Backend)
+	}
+
+	g := &GeminiLLMProvider{
+		name:    cfg.Name,
+		cfg:     clientCfg,
+		params:  cfg.GenerationParams,
+		retry:   newRetrier(cfg, logger),
+		logger:  logger,
+		clients: make(map[string]*genai.Client),
+	}
+	// fail early on an invalid config
+	if _, err := g.client(ctx, cfg.APIKey); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// vertexConfig sets up clientCfg for Vertex AI. Without transport options
+// genai creates the authenticated HTTP client itself.
+func vertexConfig(ctx context.Context, cfg LLMConfig, clientCfg *genai.ClientConfig) error {
+	if cfg.Project == "" || cfg.Location == "" {
+		return fmt.Errorf("the vertex Backend needs Project and Location")
+	}
+	clientCfg.Backend = genai.BackendVertexAI
+	clientCfg.Project = cfg.Project
+	clientCfg.Location = cfg.Location
+
+	creds, err := credentials.DetectDefault(&credentials.DetectOptions{
+		Scopes:          []string{"https://www.googleapis.com/auth/cloud-platform"},
+		CredentialsFile: cfg.CredentialsFile,
+		Client:          clientCfg.HTTPClient, // token requests go through the proxy too
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create gemini client: %v", err)
+		return fmt.Errorf("failed to get Google credentials: %v", err)
+	}
+	clientCfg.Credentials = creds
+
+	if clientCfg.HTTPClient != nil {
+		authClient, err := httptransport.NewClient(&httptransport.Options{
+			Credentials:      creds,
+			BaseRoundTripper: clientCfg.HTTPClient.Transport,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create HTTP client: %v", err)
+		}
+		authClient.Timeout = clientCfg.HTTPClient.Timeout
+		clientCfg.HTTPClient = authClient
 	}
-	return &GeminiLLMProvider{client: geminiClient, logger: logger}, nil
+	return nil
 }
+
 func toolsToGoogle(tools []mcp.Tool) ([]*genai.Tool, error) {
 	functionDeclarations := []*genai.FunctionDeclaration{}
 
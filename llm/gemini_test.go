@@ -0,0 +1,82 @@
+package llm
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kk2simon/ghost-cli/tools"
+)
+
+// TestVertexBackend runs a chat against a stand-in for Vertex AI and its token endpoint.
+func TestVertexBackend(t *testing.T) {
+	var paths, auths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/token" {
+			json.NewEncoder(w).Encode(map[string]any{"access_token": "vertex-token", "token_type": "Bearer", "expires_in": 3600})
+			return
+		}
+		paths = append(paths, r.URL.Path)
+		auths = append(auths, r.Header.Get("Authorization"))
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []any{map[string]any{"content": map[string]any{"role": "model", "parts": []any{map[string]any{"text": "hello"}}}}},
+		})
+	}))
+	defer srv.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	credsFile := filepath.Join(t.TempDir(), "sa.json")
+	creds, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"project_id":   "ghost-test",
+		"private_key":  string(keyPEM),
+		"client_email": "ghost@ghost-test.iam.gserviceaccount.com",
+		"token_uri":    srv.URL + "/token",
+	})
+	if err := os.WriteFile(credsFile, creds, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, timeout := range []time.Duration{0, time.Minute} { // genai's HTTP client and ghost's
+		cfg := LLMConfig{Name: "vertex", APIType: "gemini", Backend: "vertex", Host: srv.URL + "/",
+			Project: "ghost-test", Location: "europe-west4", CredentialsFile: credsFile, RequestTimeout: timeout}
+		p, err := BuildLLMProvider(context.Background(), cfg, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply, err := p.Chat(context.Background(), Prompt{User: "hi"}, "gemini-2.5-flash", &tools.Runtime{}, SingleTurn)
+		if err != nil || reply != "hello" {
+			t.Fatalf("Chat() = %q, %v", reply, err)
+		}
+	}
+	if len(paths) != 2 {
+		t.Fatalf("requests = %v, want 2", paths)
+	}
+	for i, path := range paths {
+		if !strings.Contains(path, "/projects/ghost-test/locations/europe-west4/") || auths[i] != "Bearer vertex-token" {
+			t.Errorf("request %d: %s with Authorization %q", i, path, auths[i])
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package llm
+
+import "fmt"
+
+// Message is a conversation entry in a form every provider understands, so
+// that a conversation can continue with a fallback LLM.
+type Message struct {
+	Role string // "user", "assistant" or "tool"
+	Text string
+	// ToolCalls are the tool calls of an assistant message.
+	ToolCalls []MessageToolCall
+	// ToolCallID and ToolName identify the call a tool message answers,
+	// Failed marks error results.
+	ToolCallID string
+	ToolName   string
+	Failed     bool
+}
+
+// MessageToolCall is a tool call requested by the LLM.
+type MessageToolCall struct {
+	ID        string
+	Name      string
+	Arguments map[string]any
+}
+
+// ProviderError is returned by Chat when requests to the LLM API keep failing.
+// History holds the conversation up to the failed request, which is its last
+// entry, so that another LLM can continue it.
+type ProviderError struct {
+	LLM     string
+	Err     error
+	History []Message
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("LLM %s: %v", e.LLM, e.Err)
+}
+
+func (e *ProviderError) Unwrap() error { return e.Err }
+
+// startHistory returns the conversation a Chat starts with: the carried-over
+// history, then the user prompt if any.
+func startHistory(prompt Prompt) []Message {
+	history := append([]Message(nil), prompt.History...)
+	if prompt.User != "" {
+		history = append(history, Message{Role: "user", Text: prompt.User})
+	}
+	return withToolCallIDs(history)
+}
+
+// withToolCallIDs fills in the IDs of tool calls and results that have none,
+// as Gemini may send, since the OpenAI APIs need them to pair results with calls.
+func withToolCallIDs(history []Message) []Message {
+	var pending []string // IDs of calls not answered yet, in order
+	for i := range history {
+		m := &history[i]
+		switch m.Role {
+		case "assistant":
+			pending = pending[:0]
+			m.ToolCalls = append([]MessageToolCall(nil), m.ToolCalls...) // may be shared with the caller's history
+			for j := range m.ToolCalls {
+				if m.ToolCalls[j].ID == "" {
+					m.ToolCalls[j].ID = fmt.Sprintf("call_%d_%d", i, j)
+				}
+				pending = append(pending, m.ToolCalls[j].ID)
+			}
+		case "tool":
+			if m.ToolCallID == "" && len(pending) > 0 {
+				m.ToolCallID = pending[0]
+			}
+			for j, id := range pending {
+				if id == m.ToolCallID {
+					pending = append(pending[:j], pending[j+1:]...)
+					break
+				}
+			}
+		}
+	}
+	return history
+}
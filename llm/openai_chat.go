@@ -5,9 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"strings"
 
-	"github.com/fatih/color"
-	"github.com/kk2simon/ghost-cli/cli"
 	"github.com/kk2simon/ghost-cli/tools"
 
 	"github.com/mark3labs/mcp-go/mcp"
@@ -16,8 +15,13 @@ import (
 )
 
 type OpenaiChatLLMProvider struct {
+	name   string
 	client *openai.Client
-	logger *slog.Logger
+	// keyOption sets the API key of a request
+	keyOption func(apiKey string) []option.RequestOption
+	params    GenerationParams
+	retry     *retrier
+	logger    *slog.Logger
 }
 
 func (o *OpenaiChatLLMProvider) APIType() string {
@@ -26,24 +30,41 @@ func (o *OpenaiChatLLMProvider) APIType() string {
 
 // Chat-loop using the official SDK + tool calling.
 func (o *OpenaiChatLLMProvider) Chat(
-	ctx context.Context, prompt Prompt, model string, tools []mcp.Tool, toolCaller tools.ToolCaller) (string, error) {
-
-	openaiTools, err := buildOpenAITools(tools)
-	if err != nil {
-		return "", err
-	}
+	ctx context.Context, prompt Prompt, model string, toolsRuntime *tools.Runtime, interact Interact) (string, error) {
 
 	// Initial payload
+	history := startHistory(prompt)
 	params := openai.ChatCompletionNewParams{
 		Model:    openai.ChatModel(model),
-		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage(prompt.User)},
-		Tools:    openaiTools,
+		Messages: chatMessages(history),
+	}
+	o.params.applyChat(&params)
+	// record appends a message to both the request and the history
+	record := func(msg openai.ChatCompletionMessageParamUnion, m Message) {
+		params.Messages = append(params.Messages, msg)
+		history = append(history, m)
 	}
 
+	runner := &toolRunner{rt: toolsRuntime}
+	var toolsVersion uint64
 	for {
-		completion, err := o.client.Chat.Completions.New(ctx, params)
+		if ts, version := toolsRuntime.Tools(); version != toolsVersion {
+			openaiTools, err := buildOpenAITools(ts)
+			if err != nil {
+				return "", err
+			}
+			params.Tools = openaiTools
+			toolsVersion = version
+		}
+
+		var completion *openai.ChatCompletion
+		err := o.retry.do(ctx, func(apiKey string) error {
+			var err error
+			completion, err = o.client.Chat.Completions.New(ctx, params, o.keyOption(apiKey)...)
+			return err
+		})
 		if err != nil {
-			return "", err
+			return "", &ProviderError{LLM: o.name, Err: err, History: history}
 		}
 		if len(completion.Choices) == 0 {
 			// TODO unfriendly to normal user
@@ -59,62 +80,159 @@ func (o *OpenaiChatLLMProvider) Chat(
 
 		// No tool calls → conversation finished
 		if len(msg.ToolCalls) == 0 {
-			color.Cyan("LLM:" + msg.Content)
-
-			// Ask user for new prompt
-			userInput, err := cli.PromptUser()
+			record(msg.ToParam(), Message{Role: "assistant", Text: msg.Content})
+			// Hand the reply over and ask for new prompt
+			userInput, err := interact(msg.Content)
 			if err != nil {
-				return "", fmt.Errorf("error reading user input: %w", err)
+				return "", err
 			}
-			// If user input is empty or exit command, break the loop
-			if userInput == "" || userInput == "exit" { // Define an exit command
-				return "", nil // Return nil error to indicate successful chat end
+			// If user input is empty, break the loop
+			if userInput == "" {
+				return msg.Content, nil // Return nil error to indicate successful chat end
 			}
+			runner.userTurn()
 			// Append user message and continue chat loop
-			params.Messages = append(params.Messages, openai.UserMessage(userInput))
+			record(openai.UserMessage(userInput), Message{Role: "user", Text: userInput})
 			continue
 		}
 
 		// Record assistant message
-		params.Messages = append(params.Messages, msg.ToParam())
+		assistant := Message{Role: "assistant", Text: msg.Content}
+		calls := make([]map[string]any, len(msg.ToolCalls))
+		argErrs := make([]error, len(msg.ToolCalls))
+		for i, call := range msg.ToolCalls {
+			calls[i], argErrs[i] = parseToolArguments(call.Function.Arguments)
+			assistant.ToolCalls = append(assistant.ToolCalls, MessageToolCall{ID: call.ID, Name: call.Function.Name, Arguments: calls[i]})
+		}
+		record(msg.ToParam(), assistant)
 
 		// Handle every tool call in this turn
-		for _, call := range msg.ToolCalls {
-			var args map[string]any
-			if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
-				return "", fmt.Errorf("tool %s: bad args: %w", call.Function.Name, err)
-			}
-
-			res, err := toolCaller(call.Function.Name, args)
-			if err != nil {
-				return "", fmt.Errorf("tool %s failed: %w", call.Function.Name, err)
-			}
-
-			toolOutput := ""
-			if len(res.Content) > 0 {
-				if tc, ok := res.Content[0].(mcp.TextContent); ok {
-					toolOutput = tc.Text
+		for i, call := range msg.ToolCalls {
+			result := Message{Role: "tool", ToolCallID: call.ID, ToolName: call.Function.Name}
+			if argErrs[i] != nil {
+				// let the model correct itself
+				result.Text, result.Failed = runner.invalidCall(argErrs[i]), true
+			} else {
+				result.Text, result.Failed, err = runner.call(ctx, call.Function.Name, calls[i])
+				if err != nil {
+					return "", err
 				}
 			}
 
 			// Feed tool response back to the model
-			params.Messages = append(params.Messages, openai.ToolMessage(toolOutput, call.ID))
+			record(openai.ToolMessage(result.Text, call.ID), result)
 		}
 	}
+}
 
-	return "", nil
+// chatMessages converts a conversation to chat completion messages.
+func chatMessages(history []Message) []openai.ChatCompletionMessageParamUnion {
+	msgs := make([]openai.ChatCompletionMessageParamUnion, 0, len(history))
+	for _, m := range history {
+		switch m.Role {
+		case "user":
+			msgs = append(msgs, openai.UserMessage(m.Text))
+		case "assistant":
+			assistant := openai.ChatCompletionAssistantMessageParam{}
+			if m.Text != "" {
+				assistant.Content.OfString = openai.String(m.Text)
+			}
+			for _, call := range m.ToolCalls {
+				assistant.ToolCalls = append(assistant.ToolCalls, openai.ChatCompletionMessageToolCallParam{
+					ID: call.ID,
+					Function: openai.ChatCompletionMessageToolCallFunctionParam{
+						Name:      call.Name,
+						Arguments: toolArgumentsJSON(call.Arguments),
+					},
+				})
+			}
+			msgs = append(msgs, openai.ChatCompletionMessageParamUnion{OfAssistant: &assistant})
+		case "tool":
+			msgs = append(msgs, openai.ToolMessage(m.Text, m.ToolCallID))
+		}
+	}
+	return msgs
 }
 
 func NewOpenaiChatLLMProvider(cfg LLMConfig, logger *slog.Logger) (*OpenaiChatLLMProvider, error) {
-	opts := []option.RequestOption{
-		option.WithAPIKey(cfg.APIKey), // falls back to ENV if empty
+	opts, err := openaiOptions(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("LLM %s: %v", cfg.Name, err)
 	}
-	if cfg.Host != "" {
+	openaiClient := openai.NewClient(opts...)
+	return &OpenaiChatLLMProvider{name: cfg.Name, client: &openaiClient, keyOption: openaiKeyOption(cfg), params: cfg.GenerationParams, retry: newRetrier(cfg, logger), logger: logger}, nil
+}
+
+// openaiOptions returns the client options of both OpenAI APIs. The key is
+// set per request, and ghost's retrier takes care of retries.
+func openaiOptions(cfg LLMConfig) ([]option.RequestOption, error) {
+	opts := []option.RequestOption{option.WithMaxRetries(0)}
+	if cfg.Azure != nil {
+		azureOpts, err := azureOptions(cfg.Azure)
+		if err != nil {
+			return nil, err
+		}
+		// not the bearer token the SDK takes from $OPENAI_API_KEY
+		opts = append(opts, option.WithHeaderDel("Authorization"))
+		opts = append(opts, azureOpts...)
+	} else if cfg.Host != "" {
 		opts = append(opts, option.WithBaseURL(cfg.Host))
 	}
-	openaiClient := openai.NewClient(opts...)
-	return &OpenaiChatLLMProvider{client: &openaiClient, logger: logger}, nil
+	opts = append(opts, openaiKeyOption(cfg)(cfg.APIKey)...)
+	httpClient, err := cfg.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	if httpClient != nil {
+		opts = append(opts, option.WithHTTPClient(httpClient))
+	}
+	for name, value := range cfg.Headers {
+		opts = append(opts, option.WithHeader(name, value))
+	}
+	return opts, nil
 }
+
+// openaiKeyOption returns how the key is set on a request: as bearer token
+// for OpenAI, in the api-key header for Azure. Without a key the SDK uses
+// $OPENAI_API_KEY.
+func openaiKeyOption(cfg LLMConfig) func(apiKey string) []option.RequestOption {
+	return func(apiKey string) []option.RequestOption {
+		switch {
+		case apiKey == "":
+			return nil
+		case cfg.Azure != nil:
+			return []option.RequestOption{option.WithHeader("Api-Key", apiKey)}
+		default:
+			return []option.RequestOption{option.WithAPIKey(apiKey)}
+		}
+	}
+}
+
+// toolArgumentsJSON encodes tool call arguments for the OpenAI APIs.
+func toolArgumentsJSON(args map[string]any) string {
+	if args == nil {
+		return "{}"
+	}
+	raw, err := json.Marshal(args)
+	if err != nil {
+		return "{}"
+	}
+	return string(raw)
+}
+
+// parseToolArguments decodes the JSON arguments of a tool call. The error
+// is meant to be sent back to the model.
+func parseToolArguments(arguments string) (map[string]any, error) {
+	var args map[string]any
+	if strings.TrimSpace(arguments) == "" { // some models send nothing for tools without parameters
+		return args, nil
+	}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return nil, fmt.Errorf("Error: tool arguments are not a valid JSON object: %v", err)
+	}
+	return args, nil
+}
+
 func buildOpenAITools(tools []mcp.Tool) ([]openai.ChatCompletionToolParam, error) {
 	out := make([]openai.ChatCompletionToolParam, 0, len(tools))
 	for _, t := range tools {
@@ -6,9 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 
-	"github.com/fatih/color"
 	"github.com/kk2simon/ghost-cli/base"
-	"github.com/kk2simon/ghost-cli/cli"
 	"github.com/kk2simon/ghost-cli/tools"
 
 	"github.com/mark3labs/mcp-go/mcp"
@@ -20,8 +18,13 @@ import (
 )
 
 type OpenaiResponseLLMProvider struct {
+	name   string
 	client *openai.Client
-	logger *slog.Logger
+	// keyOption sets the API key of a request
+	keyOption func(apiKey string) []option.RequestOption
+	params    GenerationParams
+	retry     *retrier
+	logger    *slog.Logger
 }
 
 func (o *OpenaiResponseLLMProvider) APIType() string { return "openairesponse" }
@@ -31,34 +34,54 @@ func (o *OpenaiResponseLLMProvider) Chat(
 	ctx context.Context,
 	prompt Prompt,
 	model string,
-	toolsDesc []mcp.Tool,
-	toolCaller tools.ToolCaller,
+	toolsRuntime *tools.Runtime,
+	interact Interact,
 ) (string, error) {
-	openaiTools, err := buildOpenAIResponsesTools(toolsDesc)
-	if err != nil {
-		return "", err
+	var openaiTools []responses.ToolUnionParam
+	var toolsVersion uint64
+
+	if unsupported := o.params.unsupportedByResponses(); len(unsupported) > 0 {
+		o.logger.Warn("Generation params not supported by the Responses API", "llm", o.name, "params", unsupported)
 	}
 
+	runner := &toolRunner{rt: toolsRuntime}
 	var localConversationID string
+	// the API keeps the conversation, history is only kept for fallback LLMs
+	history := startHistory(prompt)
 	chatInput := responses.ResponseNewParamsInputUnion{
-		OfString: openai.String(prompt.User),
+		OfInputItemList: responsesInput(history),
 	}
 
 	for {
+		if ts, version := toolsRuntime.Tools(); version != toolsVersion {
+			var err error
+			openaiTools, err = buildOpenAIResponsesTools(ts)
+			if err != nil {
+				return "", err
+			}
+			toolsVersion = version
+		}
+
 		params := responses.ResponseNewParams{
 			Model: shared.ResponsesModel(model),
 			Input: chatInput,
 			Store: openai.Bool(true),
 			Tools: openaiTools,
 		}
+		o.params.applyResponses(&params)
 
 		if localConversationID != "" {
 			params.PreviousResponseID = param.NewOpt(localConversationID)
 		}
 
-		resp, err := o.client.Responses.New(ctx, params)
+		var resp *responses.Response
+		err := o.retry.do(ctx, func(apiKey string) error {
+			var err error
+			resp, err = o.client.Responses.New(ctx, params, o.keyOption(apiKey)...)
+			return err
+		})
 		if err != nil {
-			return "", err
+			return "", &ProviderError{LLM: o.name, Err: err, History: history}
 		}
 
 		localConversationID = resp.ID
@@ -66,8 +89,15 @@ func (o *OpenaiResponseLLMProvider) Chat(
 
 		o.logger.Debug(base.MustPrettyJSON(resp.Output), "text", "respJSON")
 
+		if resp.Status == responses.ResponseStatusIncomplete {
+			// function call arguments may be cut off, so don't run anything
+			return "", fmt.Errorf("LLM %s: response incomplete (%s), consider raising MaxOutputTokens", o.name, resp.IncompleteDetails.Reason)
+		}
+
 		// chat not completed, try to process outputs
 		tmpInput := responses.ResponseNewParamsInputUnion{}
+		assistant := Message{Role: "assistant", Text: resp.OutputText()}
+		var results []Message
 
 		for _, out := range resp.Output {
 			switch out.Type {
@@ -76,28 +106,22 @@ func (o *OpenaiResponseLLMProvider) Chat(
 			case "function_call":
 				call := out.AsFunctionCall()
 
-				var args map[string]any
-				if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
-					return "", fmt.Errorf("tool %s: bad args: %w", call.Name, err)
-				}
-
-				toolCallResp, err := toolCaller(call.Name, args)
+				args, _ := parseToolArguments(call.Arguments)
+				assistant.ToolCalls = append(assistant.ToolCalls, MessageToolCall{ID: call.CallID, Name: call.Name, Arguments: args})
+				toolOutput, failed, err := o.callTool(ctx, runner, call)
 				if err != nil {
-					return "", fmt.Errorf("tool %s failed: %w", call.Name, err)
-				}
-
-				toolOutput := ""
-				if len(toolCallResp.Content) > 0 {
-					if tc, ok := toolCallResp.Content[0].(mcp.TextContent); ok {
-						toolOutput = tc.Text
-					}
+					return "", err
 				}
+				results = append(results, Message{Role: "tool", Text: toolOutput, ToolCallID: call.CallID, ToolName: call.Name, Failed: failed})
 
 				o.logger.Debug(base.MustPrettyJSON(call), "info", "callJSON")
 
 				tmpInput.OfInputItemList = append(tmpInput.OfInputItemList, responses.ResponseInputItemParamOfFunctionCallOutput(
 					call.CallID, toolOutput,
 				))
+			case "reasoning":
+				// kept by the API and sent back through PreviousResponseID
+				o.logger.Debug("Reasoning output skipped", "ID", out.ID)
 
 			default:
 				return "", fmt.Errorf("unhandled output type: %s", out.Type)
@@ -105,17 +129,22 @@ func (o *OpenaiResponseLLMProvider) Chat(
 
 			chatInput = tmpInput
 		}
+		history = append(history, assistant)
+		history = append(history, results...)
 
-		if len(tmpInput.OfInputItemList) == 0 && resp.Status == responses.ResponseStatusCompleted {
-			color.Cyan("LLM: %s", resp.OutputText())
-
-			usrInput, err := cli.PromptUser()
+		if len(tmpInput.OfInputItemList) == 0 {
+			if resp.Status != responses.ResponseStatusCompleted {
+				return "", fmt.Errorf("LLM %s: response %s without output to continue from", o.name, resp.Status)
+			}
+			usrInput, err := interact(resp.OutputText())
 			if err != nil {
-				return "", fmt.Errorf("error reading user input: %w", err)
+				return "", err
 			}
-			if usrInput == "" || usrInput == "exit" {
-				return "", nil
+			if usrInput == "" {
+				return resp.OutputText(), nil
 			}
+			runner.userTurn()
+			history = append(history, Message{Role: "user", Text: usrInput})
 			chatInput = responses.ResponseNewParamsInputUnion{
 				OfString: openai.String(usrInput),
 			}
@@ -124,6 +153,37 @@ func (o *OpenaiResponseLLMProvider) Chat(
 	}
 }
 
+// callTool runs a function call and returns the output for the model and whether it failed.
+func (o *OpenaiResponseLLMProvider) callTool(ctx context.Context, runner *toolRunner, call responses.ResponseFunctionToolCall) (string, bool, error) {
+	args, err := parseToolArguments(call.Arguments)
+	if err != nil {
+		return runner.invalidCall(err), true, nil // let the model correct itself
+	}
+
+	return runner.call(ctx, call.Name, args)
+}
+
+// responsesInput converts a conversation to Responses API input items.
+func responsesInput(history []Message) []responses.ResponseInputItemUnionParam {
+	items := make([]responses.ResponseInputItemUnionParam, 0, len(history))
+	for _, m := range history {
+		switch m.Role {
+		case "user":
+			items = append(items, responses.ResponseInputItemParamOfMessage(m.Text, responses.EasyInputMessageRoleUser))
+		case "assistant":
+			if m.Text != "" {
+				items = append(items, responses.ResponseInputItemParamOfMessage(m.Text, responses.EasyInputMessageRoleAssistant))
+			}
+			for _, call := range m.ToolCalls {
+				items = append(items, responses.ResponseInputItemParamOfFunctionCall(toolArgumentsJSON(call.Arguments), call.ID, call.Name))
+			}
+		case "tool":
+			items = append(items, responses.ResponseInputItemParamOfFunctionCallOutput(m.ToolCallID, m.Text))
+		}
+	}
+	return items
+}
+
 func buildOpenAIResponsesTools(tools []mcp.Tool) ([]responses.ToolUnionParam, error) {
 	out := make([]responses.ToolUnionParam, 0, len(tools))
 	for _, t := range tools {
@@ -143,10 +203,10 @@ func buildOpenAIResponsesTools(tools []mcp.Tool) ([]responses.ToolUnionParam, er
 }
 
 func NewOpenaiResponseLLMProvider(cfg LLMConfig, logger *slog.Logger) (*OpenaiResponseLLMProvider, error) {
-	opts := []option.RequestOption{option.WithAPIKey(cfg.APIKey)}
-	if cfg.Host != "" {
-		opts = append(opts, option.WithBaseURL(cfg.Host))
+	opts, err := openaiOptions(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("LLM %s: %v", cfg.Name, err)
 	}
 	client := openai.NewClient(opts...)
-	return &OpenaiResponseLLMProvider{client: &client, logger: logger}, nil
+	return &OpenaiResponseLLMProvider{name: cfg.Name, client: &client, keyOption: openaiKeyOption(cfg), params: cfg.GenerationParams, retry: newRetrier(cfg, logger), logger: logger}, nil
 }
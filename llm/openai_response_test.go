@@ -0,0 +1,68 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kk2simon/ghost-cli/tools"
+)
+
+func TestResponsesOutput(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	message := map[string]any{
+		"type": "message", "id": "msg_1", "role": "assistant", "status": "completed",
+		"content": []any{map[string]any{"type": "output_text", "text": "done", "annotations": []any{}}},
+	}
+	reasoning := map[string]any{"type": "reasoning", "id": "rs_1", "summary": []any{}}
+
+	for _, tc := range []struct {
+		name    string
+		resp    map[string]any
+		want    string
+		wantErr string
+	}{
+		{
+			name: "reasoning is skipped",
+			resp: map[string]any{"status": "completed", "output": []any{reasoning, message}},
+			want: "done",
+		},
+		{
+			name:    "incomplete",
+			resp:    map[string]any{"status": "incomplete", "incomplete_details": map[string]any{"reason": "max_output_tokens"}, "output": []any{reasoning}},
+			wantErr: "response incomplete (max_output_tokens)",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				resp := map[string]any{"id": "resp_1", "object": "response", "model": "m", "created_at": 1}
+				for k, v := range tc.resp {
+					resp[k] = v
+				}
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(resp)
+			}))
+			defer srv.Close()
+
+			p, err := BuildLLMProvider(context.Background(), LLMConfig{Name: "a", APIType: "openairesponse", Host: srv.URL, APIKey: "x", MaxRetries: -1}, logger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.Chat(context.Background(), Prompt{User: "hi"}, "m", &tools.Runtime{}, SingleTurn)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Chat() error = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Fatalf("Chat() = %q, %v, want %q", got, err, tc.want)
+			}
+		})
+	}
+}
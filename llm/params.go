@@ -0,0 +1,142 @@
+package llm
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/responses"
+	"github.com/openai/openai-go/shared"
+	"google.golang.org/genai"
+)
+
+// GenerationParams are the sampling and output options sent with every
+// request. Unset fields are left to the API's defaults.
+type GenerationParams struct {
+	Temperature     *float64
+	TopP            *float64
+	MaxOutputTokens int
+	// Seed must fit in 32 bits, the size Gemini takes.
+	Seed          *int64
+	StopSequences []string
+	// ReasoningEffort is "low", "medium" or "high", for reasoning models.
+	// On Gemini it picks a thinking budget, unless ThinkingBudget is set.
+	ReasoningEffort string
+	// ThinkingBudget is the number of thinking tokens of Gemini models,
+	// 0 disables thinking and -1 lets the model decide.
+	ThinkingBudget *int
+}
+
+var reasoningEfforts = []string{"", "low", "medium", "high"}
+
+// geminiThinkingBudgets are the thinking budgets ReasoningEffort stands for on Gemini.
+var geminiThinkingBudgets = map[string]int32{"low": 1024, "medium": 8192, "high": 24576}
+
+// Override returns p with the fields set in o replacing its own.
+func (p GenerationParams) Override(o GenerationParams) GenerationParams {
+	v, ov := reflect.ValueOf(&p).Elem(), reflect.ValueOf(o)
+	for i := 0; i < v.NumField(); i++ {
+		if !ov.Field(i).IsZero() {
+			v.Field(i).Set(ov.Field(i))
+		}
+	}
+	return p
+}
+
+// Validate checks the values of the set fields.
+func (p GenerationParams) Validate() error {
+	switch {
+	case p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2):
+		return fmt.Errorf("Temperature %v is not between 0 and 2", *p.Temperature)
+	case p.TopP != nil && (*p.TopP < 0 || *p.TopP > 1):
+		return fmt.Errorf("TopP %v is not between 0 and 1", *p.TopP)
+	case p.MaxOutputTokens < 0:
+		return fmt.Errorf("MaxOutputTokens %d is negative", p.MaxOutputTokens)
+	case p.Seed != nil && (*p.Seed < math.MinInt32 || *p.Seed > math.MaxInt32):
+		return fmt.Errorf("Seed %d does not fit in 32 bits", *p.Seed)
+	case !slices.Contains(reasoningEfforts, p.ReasoningEffort):
+		return fmt.Errorf("invalid ReasoningEffort %q, use low, medium or high", p.ReasoningEffort)
+	case p.ThinkingBudget != nil && *p.ThinkingBudget < -1:
+		return fmt.Errorf("ThinkingBudget %d is less than -1", *p.ThinkingBudget)
+	}
+	return nil
+}
+
+// applyChat sets the params on a chat completion request. ThinkingBudget has no equivalent.
+func (p GenerationParams) applyChat(params *openai.ChatCompletionNewParams) {
+	if p.Temperature != nil {
+		params.Temperature = openai.Float(*p.Temperature)
+	}
+	if p.TopP != nil {
+		params.TopP = openai.Float(*p.TopP)
+	}
+	if p.MaxOutputTokens > 0 {
+		params.MaxCompletionTokens = openai.Int(int64(p.MaxOutputTokens))
+	}
+	if p.Seed != nil {
+		params.Seed = openai.Int(*p.Seed)
+	}
+	if len(p.StopSequences) > 0 {
+		params.Stop = openai.ChatCompletionNewParamsStopUnion{OfStringArray: p.StopSequences}
+	}
+	if p.ReasoningEffort != "" {
+		params.ReasoningEffort = shared.ReasoningEffort(p.ReasoningEffort)
+	}
+}
+
+// applyResponses sets the params on a Responses API request. The API has no
+// seed, stop sequences or thinking budget.
+func (p GenerationParams) applyResponses(params *responses.ResponseNewParams) {
+	if p.Temperature != nil {
+		params.Temperature = openai.Float(*p.Temperature)
+	}
+	if p.TopP != nil {
+		params.TopP = openai.Float(*p.TopP)
+	}
+	if p.MaxOutputTokens > 0 {
+		params.MaxOutputTokens = openai.Int(int64(p.MaxOutputTokens))
+	}
+	if p.ReasoningEffort != "" {
+		params.Reasoning = shared.ReasoningParam{Effort: shared.ReasoningEffort(p.ReasoningEffort)}
+	}
+}
+
+// unsupportedByResponses returns the names of the set params the Responses API ignores.
+func (p GenerationParams) unsupportedByResponses() []string {
+	var names []string
+	if p.Seed != nil {
+		names = append(names, "Seed")
+	}
+	if len(p.StopSequences) > 0 {
+		names = append(names, "StopSequences")
+	}
+	if p.ThinkingBudget != nil {
+		names = append(names, "ThinkingBudget")
+	}
+	return names
+}
+
+// applyGemini sets the params on a Gemini request.
+func (p GenerationParams) applyGemini(config *genai.GenerateContentConfig) {
+	if p.Temperature != nil {
+		config.Temperature = genai.Ptr(float32(*p.Temperature))
+	}
+	if p.TopP != nil {
+		config.TopP = genai.Ptr(float32(*p.TopP))
+	}
+	if p.MaxOutputTokens > 0 {
+		config.MaxOutputTokens = int32(p.MaxOutputTokens)
+	}
+	if p.Seed != nil {
+		config.Seed = genai.Ptr(int32(*p.Seed))
+	}
+	config.StopSequences = p.StopSequences
+	switch {
+	case p.ThinkingBudget != nil:
+		config.ThinkingConfig = &genai.ThinkingConfig{ThinkingBudget: genai.Ptr(int32(*p.ThinkingBudget))}
+	case p.ReasoningEffort != "":
+		config.ThinkingConfig = &genai.ThinkingConfig{ThinkingBudget: genai.Ptr(geminiThinkingBudgets[p.ReasoningEffort])}
+	}
+}
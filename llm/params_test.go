@@ -0,0 +1,53 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kk2simon/ghost-cli/tools"
+)
+
+func TestGenerationParams(t *testing.T) {
+	base := GenerationParams{Temperature: new(float64), MaxOutputTokens: 100}
+	seed := int64(42)
+	params := base.Override(GenerationParams{Seed: &seed, StopSequences: []string{"END"}})
+	if params.Temperature == nil || params.Seed == nil || params.MaxOutputTokens != 100 {
+		t.Fatalf("Override() = %+v", params)
+	}
+
+	api := &fakeChatAPI{}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	p, err := BuildLLMProvider(context.Background(), LLMConfig{Name: "a", APIType: "openaichat", Host: srv.URL, APIKey: "x", GenerationParams: params}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Chat(context.Background(), Prompt{User: "hi"}, "m", &tools.Runtime{}, SingleTurn); err != nil {
+		t.Fatal(err)
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal([]byte(api.bodies[0]), &body); err != nil {
+		t.Fatal(err)
+	}
+	// temperature 0 must be sent, not left out as unset
+	if body["temperature"] != 0.0 || body["seed"] != 42.0 || body["max_completion_tokens"] != 100.0 {
+		t.Errorf("request = %s", api.bodies[0])
+	}
+	if stop, _ := body["stop"].([]any); len(stop) != 1 {
+		t.Errorf("stop = %v, want [END]", body["stop"])
+	}
+
+	if err := (GenerationParams{ReasoningEffort: "extreme"}).Validate(); err == nil {
+		t.Error("invalid ReasoningEffort accepted")
+	}
+	bigSeed := int64(1) << 32
+	if err := (GenerationParams{Seed: &bigSeed}).Validate(); err == nil {
+		t.Error("Seed out of the 32-bit range accepted")
+	}
+}
@@ -4,25 +4,79 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"time"
 
+	"github.com/fatih/color"
+	"github.com/kk2simon/ghost-cli/base"
+	"github.com/kk2simon/ghost-cli/cli"
 	"github.com/kk2simon/ghost-cli/tools"
-
-	"github.com/mark3labs/mcp-go/mcp"
 )
 
 type LLMConfig struct {
 	Name    string
 	APIType string // openai | gemini
-	APIKey  string
+	// APIKey is the key, or a reference resolved when the LLM is used:
+	// "env:NAME", "file:PATH" or "cmd:COMMAND", see base.ResolveSecret.
+	APIKey string
+	// APIKeyFile and APIKeyCommand are alternatives to APIKey, e.g.
+	// APIKeyCommand = "pass show openai".
+	APIKeyFile    string
+	APIKeyCommand string
+	// APIKeys is a pool of keys, or references, used in turn when one is rate
+	// limited. It is another alternative to APIKey.
+	APIKeys []string
 	Host    string
 	Model   string
 
+	// Backend of the gemini APIType: "" for the Gemini API, or "vertex" for
+	// Vertex AI in Project and Location, authenticated with the service
+	// account of CredentialsFile or Application Default Credentials.
+	Backend         string
+	Project         string
+	Location        string
+	CredentialsFile string
+
+	// Azure, if set, sends the openaichat and openairesponse requests to an
+	// Azure OpenAI resource instead of Host.
+	Azure *AzureConfig
+
+	// Proxy is the URL of the HTTP proxy, by default $HTTPS_PROXY etc. are used.
+	Proxy string
+	// CACertFile holds PEM certificates trusted besides the system's.
+	CACertFile string
+	// Headers are sent with every request, values may be secret references like APIKey.
+	Headers map[string]string
+	// RequestTimeout limits each request, including reading the reply.
+	RequestTimeout     time.Duration
+	InsecureSkipVerify bool
+
+	// MaxRetries is how often a failed request is retried, for rate limits,
+	// server errors and network errors. 0 means 3, a negative value disables retries.
+	MaxRetries int
+	// Fallback names the LLMs that continue the chat, in order, when requests
+	// to this one keep failing.
+	Fallback []string
+
+	GenerationParams
+
 	// OpenaiResponse struct {
 	// 	DeleteConversation bool // whether delete conversation after chat
 	// }
 }
 
 func BuildLLMProvider(ctx context.Context, cfg LLMConfig, logger *slog.Logger) (LLMProvider, error) {
+	apiKeys, err := cfg.resolveAPIKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get API key of LLM %s: %v", cfg.Name, err)
+	}
+	cfg.APIKey, cfg.APIKeys = apiKeys[0], apiKeys
+	if cfg.Headers, err = cfg.resolveHeaders(ctx); err != nil {
+		return nil, fmt.Errorf("Failed to get headers of LLM %s: %v", cfg.Name, err)
+	}
+	if err := cfg.GenerationParams.Validate(); err != nil {
+		return nil, fmt.Errorf("LLM %s: %v", cfg.Name, err)
+	}
+
 	switch cfg.APIType {
 	case "gemini":
 		return NewGeminiLLMProvider(ctx, cfg, logger)
@@ -38,16 +92,86 @@ func BuildLLMProvider(ctx context.Context, cfg LLMConfig, logger *slog.Logger) (
 	}
 }
 
+// resolveAPIKeys returns the keys given by APIKey, APIKeyFile, APIKeyCommand
+// or APIKeys. There is at least one, possibly empty.
+func (cfg LLMConfig) resolveAPIKeys(ctx context.Context) ([]string, error) {
+	var refs []string
+	sources := 0
+	if cfg.APIKey != "" {
+		refs = append(refs, cfg.APIKey)
+		sources++
+	}
+	if cfg.APIKeyFile != "" {
+		refs = append(refs, "file:"+cfg.APIKeyFile)
+		sources++
+	}
+	if cfg.APIKeyCommand != "" {
+		refs = append(refs, "cmd:"+cfg.APIKeyCommand)
+		sources++
+	}
+	if len(cfg.APIKeys) > 0 {
+		refs = append(refs, cfg.APIKeys...)
+		sources++
+	}
+	switch sources {
+	case 0:
+		return []string{""}, nil // the SDKs may find one in their environment variables
+	case 1:
+	default:
+		return nil, fmt.Errorf("set only one of APIKey, APIKeyFile, APIKeyCommand and APIKeys")
+	}
+
+	keys := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		key, err := base.ResolveSecret(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		base.RegisterSecret(key) // literal keys too
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
 type Prompt struct {
 	System    string
 	Developer string
 	User      string
+	// History is an earlier conversation the chat continues, User is
+	// appended to it if set.
+	History []Message
+}
+
+// Interact receives every final LLM reply (one without tool calls) and
+// returns the next user message. An empty message ends the chat.
+type Interact func(reply string) (string, error)
+
+// ConsoleInteract prints the reply and asks the user for the next message.
+func ConsoleInteract(reply string) (string, error) {
+	color.Cyan("LLM:" + reply)
+
+	userInput, err := cli.PromptUser()
+	if err != nil {
+		return "", fmt.Errorf("error reading user input: %w", err)
+	}
+	if userInput == "exit" { // Define an exit command
+		return "", nil
+	}
+	return userInput, nil
+}
+
+// SingleTurn ends the chat with the first final reply.
+func SingleTurn(reply string) (string, error) {
+	return "", nil
 }
 
 type LLMProvider interface {
 	APIType() string // openaichat, openairesponse, gemini
 
-	Chat(ctx context.Context, prompt Prompt, model string, tools []mcp.Tool, toolCaller tools.ToolCaller) (string, error)
+	// Chat runs the conversation and returns the last LLM reply. The tool
+	// declarations sent to the model are rebuilt before a request whenever
+	// toolsRuntime's tool list changed.
+	Chat(ctx context.Context, prompt Prompt, model string, toolsRuntime *tools.Runtime, interact Interact) (string, error)
 
 	// Potentially separate stream method
 	// StreamChat(...) (<-chan string, error)
@@ -0,0 +1,185 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/openai/openai-go"
+	"google.golang.org/genai"
+)
+
+const (
+	defaultMaxRetries = 3
+	retryBaseDelay    = time.Second
+	retryMaxDelay     = 60 * time.Second
+	// retryMaxWait is the longest Retry-After ghost waits for, longer waits
+	// fail the request so a fallback LLM can take over.
+	retryMaxWait = 2 * time.Minute
+)
+
+// retrier retries LLM API requests that failed for reasons that may pass,
+// with jittered exponential backoff or as long as the API asks with
+// Retry-After, and rotates through a pool of API keys on rate limits.
+type retrier struct {
+	llm        string
+	maxRetries int
+	logger     *slog.Logger
+
+	mu   sync.Mutex
+	keys []string
+	key  int // index of the key in use
+}
+
+func newRetrier(cfg LLMConfig, logger *slog.Logger) *retrier {
+	maxRetries := cfg.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultMaxRetries
+	}
+	keys := cfg.APIKeys
+	if len(keys) == 0 {
+		keys = []string{cfg.APIKey}
+	}
+	return &retrier{llm: cfg.Name, maxRetries: max(maxRetries, 0), logger: logger, keys: keys}
+}
+
+// currentKey returns the API key requests are sent with.
+func (r *retrier) currentKey() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.keys[r.key]
+}
+
+// rotateKey switches to the next key of the pool and reports whether there is another one.
+func (r *retrier) rotateKey() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.keys) < 2 {
+		return false
+	}
+	r.key = (r.key + 1) % len(r.keys)
+	return true
+}
+
+// do calls request with the current API key until it succeeds, fails in a way
+// not worth retrying, or the retries are used up.
+func (r *retrier) do(ctx context.Context, request func(apiKey string) error) error {
+	rotations := 0
+	for attempt := 0; ; attempt++ {
+		err := request(r.currentKey())
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		retryable, rateLimited, retryAfter := classifyError(err)
+		if !retryable || attempt >= r.maxRetries || retryAfter > retryMaxWait {
+			return err
+		}
+
+		// another key may not be limited, try it right away, once per key
+		if rateLimited && rotations < len(r.keys)-1 && r.rotateKey() {
+			rotations++
+			r.logger.Warn("LLM rate limited, rotating API key", "llm", r.llm, "error", err)
+			color.Yellow("LLM %s rate limited, trying the next API key", r.llm)
+			continue
+		}
+		rotations = 0
+
+		wait := retryAfter
+		if wait == 0 {
+			wait = min(retryBaseDelay<<attempt, retryMaxDelay)
+			wait = wait/2 + rand.N(wait/2+1) // jitter
+		}
+		shown := wait.Round(time.Second)
+		if wait < time.Second {
+			shown = wait.Round(time.Millisecond)
+		}
+		r.logger.Warn("LLM request failed, retrying", "llm", r.llm, "attempt", attempt+1, "wait", wait, "error", err)
+		color.Yellow("LLM %s request failed (%v), retrying in %s", r.llm, shortError(err), shown)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// classifyError tells whether a failed request may succeed when retried,
+// whether it was rate limited, and how long the API asked to wait.
+func classifyError(err error) (retryable, rateLimited bool, retryAfter time.Duration) {
+	status := 0
+	var openaiErr *openai.Error
+	var genaiErr genai.APIError
+	switch {
+	case errors.As(err, &openaiErr):
+		status = openaiErr.StatusCode
+		if openaiErr.Response != nil {
+			retryAfter = parseRetryAfter(openaiErr.Response.Header)
+		}
+	case errors.As(err, &genaiErr):
+		status = genaiErr.Code
+		retryAfter = genaiRetryDelay(genaiErr)
+	default:
+		var netErr net.Error
+		retryable = errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) ||
+			errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+		return retryable, false, 0
+	}
+
+	switch {
+	case status == http.StatusTooManyRequests:
+		return true, true, retryAfter
+	case status == http.StatusRequestTimeout, status == http.StatusConflict, status >= 500:
+		return true, false, retryAfter
+	}
+	return false, false, 0
+}
+
+// parseRetryAfter reads retry-after-ms or Retry-After, in seconds or as HTTP date.
+func parseRetryAfter(h http.Header) time.Duration {
+	if ms, err := strconv.ParseFloat(h.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	v := h.Get("Retry-After")
+	if secs, err := strconv.ParseFloat(v, 64); err == nil && secs > 0 {
+		return time.Duration(secs * float64(time.Second))
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+// genaiRetryDelay reads the retryDelay of a google.rpc.RetryInfo error detail.
+func genaiRetryDelay(err genai.APIError) time.Duration {
+	for _, d := range err.Details {
+		if delay, ok := d["retryDelay"].(string); ok {
+			if wait, err := time.ParseDuration(delay); err == nil {
+				return wait
+			}
+		}
+	}
+	return 0
+}
+
+// shortError returns the first line of an error, SDK errors can be long.
+func shortError(err error) string {
+	s := err.Error()
+	for i, c := range s {
+		if c == '\n' {
+			return s[:i]
+		}
+	}
+	if len(s) > 200 {
+		return s[:200] + "..."
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kk2simon/ghost-cli/tools"
+)
+
+// fakeChatAPI answers chat completions with the given statuses in turn, then
+// with a reply, and records the keys and bodies of the requests.
+type fakeChatAPI struct {
+	mu       sync.Mutex
+	statuses []int
+	keys     []string
+	bodies   []string
+	headers  []http.Header
+	uris     []string
+}
+
+func (f *fakeChatAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	f.keys = append(f.keys, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	f.bodies = append(f.bodies, string(body))
+	f.headers = append(f.headers, r.Header.Clone())
+	f.uris = append(f.uris, r.URL.RequestURI())
+	status := http.StatusOK
+	if len(f.statuses) > 0 {
+		status, f.statuses = f.statuses[0], f.statuses[1:]
+	}
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if status != http.StatusOK {
+		w.Header().Set("Retry-After-Ms", "10")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"error":{"message":"try again"}}`))
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"id": "1", "object": "chat.completion", "model": "m",
+		"choices": []any{map[string]any{
+			"index": 0, "finish_reason": "stop",
+			"message": map[string]any{"role": "assistant", "content": "hello"},
+		}},
+	})
+}
+
+func TestRetryAndFallback(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+
+	t.Run("rotate keys and retry", func(t *testing.T) {
+		api := &fakeChatAPI{statuses: []int{429, 500}}
+		srv := httptest.NewServer(api)
+		defer srv.Close()
+
+		p, err := BuildLLMProvider(ctx, LLMConfig{Name: "a", APIType: "openaichat", Host: srv.URL, APIKeys: []string{"key-one-1234", "key-two-1234"}}, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply, err := p.Chat(ctx, Prompt{User: "hi"}, "m", &tools.Runtime{}, SingleTurn)
+		if err != nil || reply != "hello" {
+			t.Fatalf("Chat() = %q, %v", reply, err)
+		}
+		want := []string{"key-one-1234", "key-two-1234", "key-two-1234"}
+		if strings.Join(api.keys, " ") != strings.Join(want, " ") {
+			t.Errorf("keys = %v, want %v", api.keys, want)
+		}
+	})
+
+	t.Run("fall back with history", func(t *testing.T) {
+		down := &fakeChatAPI{statuses: []int{503}}
+		up := &fakeChatAPI{}
+		downSrv, upSrv := httptest.NewServer(down), httptest.NewServer(up)
+		defer downSrv.Close()
+		defer upSrv.Close()
+
+		cfgs := []LLMConfig{
+			{Name: "a", APIType: "openaichat", Host: downSrv.URL, APIKey: "x", MaxRetries: -1, Fallback: []string{"missing", "no-model", "b"}},
+			{Name: "no-model", APIType: "openaichat", Host: downSrv.URL, APIKey: "x"},
+			{Name: "b", APIType: "openaichat", Host: upSrv.URL, APIKey: "x", Model: "fallback-model"},
+		}
+		p, err := BuildLLMChain(ctx, cfgs, cfgs[0], logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply, err := p.Chat(ctx, Prompt{User: "hi"}, "m", &tools.Runtime{}, SingleTurn)
+		if err != nil || reply != "hello" {
+			t.Fatalf("Chat() = %q, %v", reply, err)
+		}
+		if body := up.bodies[0]; !strings.Contains(body, `"fallback-model"`) || !strings.Contains(body, `"hi"`) {
+			t.Errorf("fallback request = %s, want its model and the user message", body)
+		}
+	})
+}
+
+func TestWithToolCallIDs(t *testing.T) {
+	history := withToolCallIDs([]Message{
+		{Role: "user", Text: "hi"},
+		{Role: "assistant", ToolCalls: []MessageToolCall{{Name: "a"}, {Name: "b"}}},
+		{Role: "tool", ToolName: "a"},
+		{Role: "tool", ToolName: "b"},
+	})
+	calls := history[1].ToolCalls
+	if calls[0].ID == "" || calls[0].ID == calls[1].ID {
+		t.Fatalf("tool call IDs = %q, %q", calls[0].ID, calls[1].ID)
+	}
+	if history[2].ToolCallID != calls[0].ID || history[3].ToolCallID != calls[1].ID {
+		t.Errorf("results answer %q, %q, want %q, %q", history[2].ToolCallID, history[3].ToolCallID, calls[0].ID, calls[1].ID)
+	}
+}
@@ -0,0 +1,44 @@
+package llm
+
+import (
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ToolConversion is a tool as one APIType sends it to the model.
+type ToolConversion struct {
+	APIType string `json:"apiType"`
+	Tool    any    `json:"tool,omitempty"`
+	// Error tells why the tool can't be converted; the provider then fails to start the chat.
+	Error string `json:"error,omitempty"`
+}
+
+// ConvertTool returns tool t as the gemini, openaichat and openairesponse
+// providers declare it to their APIs, for "ghost tools inspect".
+func ConvertTool(t mcp.Tool) []ToolConversion {
+	var convs []ToolConversion
+	add := func(apiType string, tool any, err error) {
+		c := ToolConversion{APIType: apiType, Tool: tool}
+		if err != nil {
+			c.Error = err.Error()
+		}
+		convs = append(convs, c)
+	}
+
+	ts := []mcp.Tool{t}
+	if gemini, err := toolsToGoogle(ts); err != nil {
+		add("gemini", nil, err)
+	} else {
+		add("gemini", gemini[0].FunctionDeclarations[0], nil)
+	}
+	if chat, err := buildOpenAITools(ts); err != nil {
+		add("openaichat", nil, err)
+	} else {
+		add("openaichat", chat[0], nil)
+	}
+	if resp, err := buildOpenAIResponsesTools(ts); err != nil {
+		add("openairesponse", nil, err)
+	} else {
+		add("openairesponse", resp[0], nil)
+	}
+	return convs
+}
@@ -0,0 +1,43 @@
+package llm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"google.golang.org/genai"
+)
+
+func TestConvertTool(t *testing.T) {
+	tool := mcp.NewTool("grep",
+		mcp.WithDescription("Search files"),
+		mcp.WithString("pattern", mcp.Required()),
+		mcp.WithArray("paths", mcp.Items(map[string]any{"type": "string"})),
+	)
+	convs := ConvertTool(tool)
+	if len(convs) != 3 {
+		t.Fatalf("ConvertTool() = %+v, want 3 conversions", convs)
+	}
+	for _, c := range convs {
+		if c.Error != "" {
+			t.Errorf("%s: %s", c.APIType, c.Error)
+		}
+		b, err := json.Marshal(c.Tool)
+		if err != nil || !strings.Contains(string(b), `"grep"`) || !strings.Contains(string(b), `"pattern"`) {
+			t.Errorf("%s: %s, %v", c.APIType, b, err)
+		}
+	}
+	if decl := convs[0].Tool.(*genai.FunctionDeclaration); decl.Parameters.Properties["paths"].Items.Type != genai.TypeString {
+		t.Errorf("gemini items = %+v", decl.Parameters.Properties["paths"].Items)
+	}
+
+	tool.InputSchema.Properties["bad"] = "not a schema"
+	convs = ConvertTool(tool)
+	if convs[0].Error == "" || convs[0].Tool != nil {
+		t.Errorf("gemini conversion of a bad schema = %+v, want error", convs[0])
+	}
+	if convs[1].Error != "" {
+		t.Errorf("openaichat conversion of a bad schema = %+v, want it passed on", convs[1])
+	}
+}
@@ -0,0 +1,82 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kk2simon/ghost-cli/tools"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// toolRunner runs the tool calls of one chat. Failed calls come back as
+// error text for the model to react to, and after too many failures in a
+// row it stops running tools until the user speaks again.
+type toolRunner struct {
+	rt       *tools.Runtime
+	failures int // consecutive failed calls
+}
+
+// call runs a tool and returns its text for the model and whether it failed.
+// Only errors the model can't do anything about, like failing to ask the
+// user for approval, are returned as error.
+func (t *toolRunner) call(ctx context.Context, name string, arguments map[string]any) (string, bool, error) {
+	if text, stopped := t.stopped(); stopped {
+		return text, true, nil
+	}
+
+	res, err := t.rt.Caller(ctx, name, arguments)
+	if err != nil {
+		return "", false, fmt.Errorf("tool %s failed: %w", name, err)
+	}
+	text := resultText(res)
+	if !res.IsError {
+		t.failures = 0
+		return text, false, nil
+	}
+	t.failures++
+	if !strings.HasPrefix(text, "Error") {
+		text = "Error: " + text
+	}
+	return text, true, nil
+}
+
+// invalidCall returns the text for the model of a call whose arguments
+// couldn't be parsed. It counts as failed call.
+func (t *toolRunner) invalidCall(err error) string {
+	if text, stopped := t.stopped(); stopped {
+		return text
+	}
+	t.failures++
+	return err.Error()
+}
+
+// stopped tells whether too many calls failed in a row, and if so the text for the model.
+func (t *toolRunner) stopped() (string, bool) {
+	limit := t.rt.MaxToolFailures
+	if limit == 0 {
+		limit = tools.DefaultMaxToolFailures
+	}
+	if limit > 0 && t.failures >= limit {
+		return fmt.Sprintf("Error: tool not run, the last %d tool calls failed. "+
+			"Stop calling tools and tell the user what went wrong.", t.failures), true
+	}
+	return "", false
+}
+
+// userTurn lets tools run again after the user's next message.
+func (t *toolRunner) userTurn() {
+	t.failures = 0
+}
+
+// resultText returns the text content of a tool result.
+func resultText(res *mcp.CallToolResult) string {
+	var texts []string
+	for _, content := range res.Content {
+		if tc, ok := content.(mcp.TextContent); ok {
+			texts = append(texts, tc.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
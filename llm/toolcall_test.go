@@ -0,0 +1,72 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kk2simon/ghost-cli/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestToolRunnerFailureLimit(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	fail := true
+	rt := &tools.Runtime{Caller: func(ctx context.Context, name string, arguments map[string]any) (*mcp.CallToolResult, error) {
+		calls++
+		if fail {
+			return mcp.NewToolResultError("boom"), nil
+		}
+		return mcp.NewToolResultText("ok"), nil
+	}}
+
+	tests := []struct {
+		limit    int
+		runs     int // calls that reach the tool, of 10
+		failures int
+	}{
+		{0, tools.DefaultMaxToolFailures, tools.DefaultMaxToolFailures},
+		{2, 2, 2},
+		{-1, 10, 10},
+	}
+	for _, tt := range tests {
+		rt.MaxToolFailures, calls, fail = tt.limit, 0, true
+		runner := &toolRunner{rt: rt}
+		var text string
+		for range 10 {
+			var failed bool
+			text, failed, _ = runner.call(ctx, "grep", nil)
+			if !failed {
+				t.Fatalf("limit %d: call did not fail", tt.limit)
+			}
+		}
+		if calls != tt.runs || runner.failures != tt.failures {
+			t.Errorf("limit %d: %d calls run, %d failures, want %d and %d", tt.limit, calls, runner.failures, tt.runs, tt.failures)
+		}
+		if tt.limit >= 0 && !strings.Contains(text, "Stop calling tools") {
+			t.Errorf("limit %d: last result %q, want stop notice", tt.limit, text)
+		}
+	}
+
+	// malformed arguments count, and the user's turn or a success resets the count
+	rt.MaxToolFailures, calls, fail = 2, 0, false
+	runner := &toolRunner{rt: rt}
+	runner.invalidCall(errors.New("Error: bad JSON"))
+	if text, failed, _ := runner.call(ctx, "grep", nil); failed || text != "ok" || runner.failures != 0 {
+		t.Errorf("call after one invalid call = %q, %v, %d failures", text, failed, runner.failures)
+	}
+	runner.invalidCall(errors.New("Error: bad JSON"))
+	runner.invalidCall(errors.New("Error: bad JSON"))
+	if text := runner.invalidCall(errors.New("Error: bad JSON")); !strings.Contains(text, "Stop calling tools") {
+		t.Errorf("third invalid call = %q, want stop notice", text)
+	}
+	if _, failed, _ := runner.call(ctx, "grep", nil); !failed || calls != 1 {
+		t.Errorf("call after limit ran the tool")
+	}
+	runner.userTurn()
+	if _, failed, _ := runner.call(ctx, "grep", nil); failed || calls != 2 {
+		t.Errorf("call after the user's turn = failed %v, %d calls", failed, calls)
+	}
+}
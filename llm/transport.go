@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/kk2simon/ghost-cli/base"
+)
+
+// hasTransportOptions reports whether the config needs its own HTTP client.
+func (cfg LLMConfig) hasTransportOptions() bool {
+	return cfg.Proxy != "" || cfg.CACertFile != "" || cfg.RequestTimeout != 0 || cfg.InsecureSkipVerify
+}
+
+// httpClient returns the HTTP client for the LLM's Proxy, CACertFile,
+// RequestTimeout and InsecureSkipVerify, or nil to use the SDK's default.
+func (cfg LLMConfig) httpClient() (*http.Client, error) {
+	if !cfg.hasTransportOptions() {
+		return nil, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Proxy != "" {
+		proxyURL, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Proxy: %v", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if cfg.CACertFile != "" || cfg.InsecureSkipVerify {
+		tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+		if cfg.CACertFile != "" {
+			pem, err := os.ReadFile(cfg.CACertFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CACertFile: %v", err)
+			}
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no PEM certificates in CACertFile %s", cfg.CACertFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &http.Client{Transport: transport, Timeout: cfg.RequestTimeout}, nil
+}
+
+// resolveHeaders returns the extra request headers, their values resolved
+// like API keys, see base.ResolveSecret.
+func (cfg LLMConfig) resolveHeaders(ctx context.Context) (map[string]string, error) {
+	headers := make(map[string]string, len(cfg.Headers))
+	for name, ref := range cfg.Headers {
+		value, err := base.ResolveSecret(ctx, ref)
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %v", name, err)
+		}
+		headers[name] = value
+	}
+	return headers, nil
+}
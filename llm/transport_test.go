@@ -0,0 +1,60 @@
+package llm
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kk2simon/ghost-cli/tools"
+)
+
+func TestTransportOptions(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	chat := func(t *testing.T, cfg LLMConfig) error {
+		t.Helper()
+		cfg.Name, cfg.APIType, cfg.APIKey, cfg.MaxRetries = "a", "openaichat", "x", -1
+		p, err := BuildLLMProvider(context.Background(), cfg, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = p.Chat(context.Background(), Prompt{User: "hi"}, "m", &tools.Runtime{}, SingleTurn)
+		return err
+	}
+
+	t.Run("proxy and headers", func(t *testing.T) {
+		proxy := &fakeChatAPI{}
+		srv := httptest.NewServer(proxy) // answers the proxied requests itself
+		defer srv.Close()
+		t.Setenv("GHOST_TEST_TEAM", "core")
+
+		err := chat(t, LLMConfig{Host: "http://llm.invalid/v1", Proxy: srv.URL, Headers: map[string]string{"X-Team": "env:GHOST_TEST_TEAM"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := proxy.headers[0].Get("X-Team"); got != "core" {
+			t.Errorf("X-Team = %q, want core", got)
+		}
+	})
+
+	t.Run("private CA", func(t *testing.T) {
+		srv := httptest.NewTLSServer(&fakeChatAPI{})
+		defer srv.Close()
+		if err := chat(t, LLMConfig{Host: srv.URL}); err == nil {
+			t.Fatal("untrusted certificate accepted")
+		}
+
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+		if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := chat(t, LLMConfig{Host: srv.URL, CACertFile: caFile}); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// resultCache holds results of tool calls without side effects, see McpConfig.CacheTTL.
+type resultCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	result  *mcp.CallToolResult
+	expires time.Time
+}
+
+// cacheable reports whether results of the server's tool may be cached.
+func (cfg McpConfig) cacheable(tool mcp.Tool) bool {
+	if cfg.CacheTTL <= 0 {
+		return false
+	}
+	return readOnly(tool) || hint(tool.Annotations.IdempotentHint) || slices.Contains(cfg.CacheTools, tool.Name)
+}
+
+// sideEffectFree reports whether running the server's tool leaves cached results valid.
+func (cfg McpConfig) sideEffectFree(tool mcp.Tool) bool {
+	return readOnly(tool) || slices.Contains(cfg.CacheTools, tool.Name)
+}
+
+func readOnly(tool mcp.Tool) bool {
+	return hint(tool.Annotations.ReadOnlyHint)
+}
+
+func hint(h *bool) bool {
+	return h != nil && *h
+}
+
+// cacheKey identifies a call by server, tool and arguments. json.Marshal
+// sorts map keys, so equal arguments give equal keys.
+func cacheKey(server, tool string, arguments map[string]any) (string, bool) {
+	b, err := json.Marshal(arguments)
+	if err != nil {
+		return "", false
+	}
+	return server + "\x00" + tool + "\x00" + string(b), true
+}
+
+func (c *resultCache) get(key string) (*mcp.CallToolResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.result, true
+}
+
+func (c *resultCache) put(key string, result *mcp.CallToolResult, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]cacheEntry)
+	}
+	c.entries[key] = cacheEntry{result: result, expires: time.Now().Add(ttl)}
+}
+
+// clear drops every entry, after a tool which may have changed what cached results saw.
+func (c *resultCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
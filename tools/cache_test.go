@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestResultCacheExpiry(t *testing.T) {
+	var c resultCache
+	res := mcp.NewToolResultText("x")
+	c.put("short", res, time.Nanosecond)
+	c.put("long", res, time.Hour)
+	time.Sleep(time.Millisecond)
+	if _, ok := c.get("short"); ok {
+		t.Error("expired entry returned")
+	}
+	if got, ok := c.get("long"); !ok || got != res {
+		t.Error("live entry not returned")
+	}
+	c.clear()
+	if _, ok := c.get("long"); ok {
+		t.Error("entry returned after clear")
+	}
+}
+
+func TestCacheable(t *testing.T) {
+	cfg := McpConfig{CacheTTL: time.Minute, CacheTools: []string{"status"}}
+	tests := []struct {
+		tool           mcp.Tool
+		cacheable      bool
+		sideEffectFree bool
+	}{
+		{mcp.NewTool("read", mcp.WithReadOnlyHintAnnotation(true)), true, true},
+		{mcp.NewTool("write", mcp.WithIdempotentHintAnnotation(true)), true, false},
+		{mcp.NewTool("status"), true, true},
+		{mcp.NewTool("touch"), false, false},
+	}
+	for _, tt := range tests {
+		if got := cfg.cacheable(tt.tool); got != tt.cacheable {
+			t.Errorf("cacheable(%s) = %v", tt.tool.Name, got)
+		}
+		if got := cfg.sideEffectFree(tt.tool); got != tt.sideEffectFree {
+			t.Errorf("sideEffectFree(%s) = %v", tt.tool.Name, got)
+		}
+	}
+	if (McpConfig{}).cacheable(tests[0].tool) {
+		t.Error("cacheable without CacheTTL")
+	}
+}
+
+func TestToolResultCache(t *testing.T) {
+	rt := startTestRuntime(t, McpConfig{CacheTTL: time.Minute, CacheTools: []string{"status"}, AutoApprove: []string{"read", "status"}})
+	var confirmed []string
+	rt.Confirm = func(call ToolCall) (Decision, error) {
+		confirmed = append(confirmed, call.Name)
+		return Decision{Approved: true}, nil
+	}
+
+	first := callText(t, rt, "read", map[string]any{"path": "a"})
+	if got := callText(t, rt, "read", map[string]any{"path": "a"}); got != first {
+		t.Errorf("repeated read = %q, want cached %q", got, first)
+	}
+	if got := callText(t, rt, "read", map[string]any{"path": "b"}); got == first {
+		t.Errorf("read of other arguments answered from cache")
+	}
+	status := callText(t, rt, "status", nil)
+	if got := callText(t, rt, "status", nil); got != status {
+		t.Errorf("repeated status, in CacheTools, = %q, want cached %q", got, status)
+	}
+
+	// a cached idempotent write is still confirmed
+	write := callText(t, rt, "write", map[string]any{"path": "a"})
+	confirmed = nil
+	if got := callText(t, rt, "write", map[string]any{"path": "a"}); got != write || len(confirmed) != 1 {
+		t.Errorf("repeated write = %q, confirmed %v, want cached %q after confirmation", got, confirmed, write)
+	}
+	rt.Confirm = DenyConfirm
+	if got := callText(t, rt, "write", map[string]any{"path": "a"}); got == write {
+		t.Error("refused write answered from cache")
+	}
+
+	// a tool with side effects clears the cache
+	rt.Confirm = ApproveConfirm
+	callText(t, rt, "touch", nil)
+	if got := callText(t, rt, "read", map[string]any{"path": "a"}); got == first {
+		t.Error("read answered from cache after a tool with side effects")
+	}
+	if got := callText(t, rt, "status", nil); got == status {
+		t.Error("status answered from cache after a tool with side effects")
+	}
+
+	// so does a failed call, which may have had effects before failing
+	read := callText(t, rt, "read", map[string]any{"path": "a"})
+	if got := callText(t, rt, "fail", nil); !strings.Contains(got, "connection lost") {
+		t.Errorf("fail = %q, want the call error", got)
+	}
+	if got := callText(t, rt, "read", map[string]any{"path": "a"}); got == read {
+		t.Error("read answered from cache after a failed call of a tool with side effects")
+	}
+}
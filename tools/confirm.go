@@ -0,0 +1,154 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/kk2simon/ghost-cli/cli"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ToolCall describes a tool call awaiting confirmation.
+type ToolCall struct {
+	// Name is the tool name as offered to the LLM.
+	Name string
+	// Server is the name of the MCP server in the config.
+	Server string
+	// Tool is the server's declaration of the tool, under the server's name.
+	Tool      mcp.Tool
+	Arguments map[string]any
+	// Dir is the working directory of the server, which relative paths in
+	// Arguments are relative to.
+	Dir string
+}
+
+// Decision is the answer of a ConfirmFunc.
+type Decision struct {
+	Approved bool
+	// Arguments, if set, replace the arguments of an approved call.
+	Arguments map[string]any
+	// Reason tells the LLM why a call was refused.
+	Reason string
+}
+
+// ConfirmFunc decides whether a tool call that is not auto-approved may run.
+type ConfirmFunc func(call ToolCall) (Decision, error)
+
+var consoleMu sync.Mutex
+
+// ConsoleConfirm shows the tool call and asks the user on stdin. The user
+// may run it, edit its arguments in $EDITOR first, or refuse with a reason.
+// File writes are shown as a diff and shell commands highlighted, see previewRenderers.
+// Concurrent calls are asked one after another.
+func ConsoleConfirm(call ToolCall) (Decision, error) {
+	consoleMu.Lock()
+	defer consoleMu.Unlock()
+
+	edited := false
+	for {
+		color.Magenta("Confirm tool call:")
+		fmt.Printf(`=======================
+Name: %s
+Arguments:
+%v
+=======================
+Press Enter to run, "e" to edit the arguments, or type a reason to refuse:
+`, call.Name, renderPreview(call))
+		answer, err := cli.PromptUser()
+		if err != nil {
+			return Decision{}, err
+		}
+
+		switch answer {
+		case "":
+			if edited {
+				return Decision{Approved: true, Arguments: call.Arguments}, nil
+			}
+			return Decision{Approved: true}, nil
+		case "e", "edit":
+			arguments, changed, err := editArguments(call.Arguments)
+			if err != nil {
+				color.Yellow("%v", err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+			if schema, err := inputSchema(call.Tool); err == nil {
+				if errs := validateArguments(schema, arguments); len(errs) > 0 {
+					color.Yellow("Edited arguments don't match the tool schema:\n- %s", strings.Join(errs, "\n- "))
+				}
+			}
+			call.Arguments = arguments
+			edited = true
+		default:
+			return Decision{Reason: answer}, nil
+		}
+	}
+}
+
+// ApproveConfirm approves every call.
+func ApproveConfirm(call ToolCall) (Decision, error) {
+	return Decision{Approved: true}, nil
+}
+
+// DenyConfirm refuses every call, for runs without a user to ask.
+func DenyConfirm(call ToolCall) (Decision, error) {
+	return Decision{Reason: "no user is available to approve this tool, only auto-approved tools can run"}, nil
+}
+
+// editArguments opens arguments as JSON in $VISUAL or $EDITOR, vi by default,
+// and returns the edited arguments and whether the user changed them.
+func editArguments(arguments map[string]any) (map[string]any, bool, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	f, err := os.CreateTemp("", "ghost-arguments-*.json")
+	if err != nil {
+		return nil, false, fmt.Errorf("Failed to create arguments file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	b, err := json.MarshalIndent(arguments, "", "  ")
+	if err != nil {
+		f.Close()
+		return nil, false, err
+	}
+	_, err = f.Write(append(b, '\n'))
+	f.Close()
+	if err != nil {
+		return nil, false, fmt.Errorf("Failed to write arguments file: %v", err)
+	}
+
+	// the editor may come with flags, e.g. "code --wait"
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], f.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, false, fmt.Errorf("Failed to run editor %s: %v", editor, err)
+	}
+
+	original := b
+	b, err = os.ReadFile(f.Name())
+	if err != nil {
+		return nil, false, fmt.Errorf("Failed to read edited arguments: %v", err)
+	}
+	if bytes.Equal(bytes.TrimSpace(b), original) {
+		return arguments, false, nil
+	}
+	var edited map[string]any
+	if err := json.Unmarshal(b, &edited); err != nil || edited == nil {
+		return nil, false, fmt.Errorf("Edited arguments are not a valid JSON object, not changed: %s", b)
+	}
+	return edited, true, nil
+}
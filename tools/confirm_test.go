@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeEditor sets $EDITOR to a shell script running script, with the file to edit as $1.
+func fakeEditor(t *testing.T, script string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "editor.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "/bin/sh "+path) // editors may come with arguments
+}
+
+func TestEditArguments(t *testing.T) {
+	arguments := map[string]any{"path": "a.txt"}
+	tests := []struct {
+		name    string
+		script  string
+		want    string
+		changed bool
+		wantErr bool
+	}{
+		{"edited", `printf '{"path": "b.txt"}' > "$1"`, "b.txt", true, false},
+		{"invalid JSON", `printf '{"path": ' > "$1"`, "", false, true},
+		{"not an object", `printf '[]' > "$1"`, "", false, true},
+		{"unchanged", `exit 0`, "a.txt", false, false},
+		{"editor fails", `exit 1`, "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeEditor(t, tt.script)
+			got, changed, err := editArguments(arguments)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("editArguments() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil || got["path"] != tt.want || changed != tt.changed {
+				t.Errorf("editArguments() = %v, %v, %v, want path %s, changed %v", got, changed, err, tt.want, tt.changed)
+			}
+		})
+	}
+}
+
+func TestConsoleConfirmEdit(t *testing.T) {
+	dir := t.TempDir()
+	edited := filepath.Join(dir, "edited")
+	tests := []struct {
+		name   string
+		script string
+		want   map[string]any // nil if the arguments are kept
+	}{
+		{"edited", `printf '{"path": "b.txt"}' > "$1"`, map[string]any{"path": "b.txt"}},
+		{"invalid JSON", `printf 'oops' > "$1"`, nil},
+		{"unchanged", `exit 0`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(edited)
+			fakeEditor(t, "touch "+edited+"\n"+tt.script)
+
+			// answer "e", then Enter once the editor ran: PromptUser reads
+			// through a new buffer each time, so lines must come one by one
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			stdin := os.Stdin
+			os.Stdin = r
+			defer func() { os.Stdin = stdin; r.Close() }()
+			go func() {
+				defer w.Close()
+				w.WriteString("e\n")
+				for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+					if _, err := os.Stat(edited); err == nil {
+						break
+					}
+				}
+				w.WriteString("\n")
+			}()
+
+			decision, err := ConsoleConfirm(ToolCall{Name: "write", Arguments: map[string]any{"path": "a.txt"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !decision.Approved {
+				t.Fatalf("decision = %+v, want approved", decision)
+			}
+			if tt.want == nil {
+				if decision.Arguments != nil {
+					t.Errorf("arguments = %v, want the original ones kept", decision.Arguments)
+				}
+			} else if got := decision.Arguments["path"]; got != tt.want["path"] {
+				t.Errorf("path = %v, want %v", got, tt.want["path"])
+			}
+		})
+	}
+}
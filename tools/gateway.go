@@ -0,0 +1,122 @@
+package tools
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// NewGateway returns an MCP server offering the union of the tools, resources
+// and prompts of all servers in rt. Tool calls go through rt.Caller, so the
+// prefixes, filters and approval policy of McpConfig apply as in a chat.
+// Lazy servers offer their cached tools until they start, and their resources
+// and prompts only once started.
+func NewGateway(ctx context.Context, rt *Runtime) *server.MCPServer {
+	s := server.NewMCPServer("ghost-gateway", "1.0.0",
+		server.WithToolCapabilities(true),
+		server.WithResourceCapabilities(false, true),
+		server.WithPromptCapabilities(true),
+	)
+
+	callTool := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return rt.Caller(ctx, request.Params.Name, request.Params.Arguments)
+	}
+	setTools := func() {
+		ts, _ := rt.Tools()
+		serverTools := make([]server.ServerTool, 0, len(ts))
+		for _, t := range ts {
+			serverTools = append(serverTools, server.ServerTool{Tool: t, Handler: callTool})
+		}
+		s.SetTools(serverTools...)
+	}
+
+	var offeredMu sync.Mutex
+	offered := make(map[*mcpServer]bool)
+	// offerStarted adds the resources and prompts of servers started since the last call
+	offerStarted := func(servers []*mcpServer) {
+		for _, srv := range servers {
+			if srv == nil {
+				continue
+			}
+			c := rt.clientOf(srv)
+			if c == nil {
+				continue
+			}
+			offeredMu.Lock()
+			done := offered[srv]
+			offered[srv] = true
+			offeredMu.Unlock()
+			if done {
+				continue
+			}
+			caps := c.GetServerCapabilities()
+			if caps.Resources != nil {
+				addGatewayResources(ctx, rt, s, srv, c)
+			}
+			if caps.Prompts != nil {
+				addGatewayPrompts(ctx, rt, s, srv, c)
+			}
+		}
+	}
+
+	rt.mu.Lock()
+	servers := rt.servers
+	rt.onToolsChanged = func() {
+		setTools()
+		offerStarted(servers)
+	}
+	rt.mu.Unlock()
+	setTools()
+	offerStarted(servers)
+	return s
+}
+
+// addGatewayResources offers the resources and resource templates of srv, using its client c, on s.
+// Resource URIs are left as they are, the first server to offer one wins.
+func addGatewayResources(ctx context.Context, rt *Runtime, s *server.MCPServer, srv *mcpServer, c *client.Client) {
+	readResource := func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		result, err := c.ReadResource(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		return result.Contents, nil
+	}
+
+	resources, err := c.ListResources(ctx, mcp.ListResourcesRequest{})
+	if err != nil {
+		rt.logger.Warn("Failed to list MCP resources", "mcp", srv.cfg.Name, "error", err)
+	} else {
+		for _, res := range resources.Resources {
+			s.AddResource(res, readResource)
+		}
+	}
+
+	templates, err := c.ListResourceTemplates(ctx, mcp.ListResourceTemplatesRequest{})
+	if err != nil {
+		rt.logger.Warn("Failed to list MCP resource templates", "mcp", srv.cfg.Name, "error", err)
+	} else {
+		for _, tpl := range templates.ResourceTemplates {
+			s.AddResourceTemplate(tpl, readResource)
+		}
+	}
+}
+
+// addGatewayPrompts offers the prompts of srv, using its client c, on s, renamed with the server's Prefix.
+func addGatewayPrompts(ctx context.Context, rt *Runtime, s *server.MCPServer, srv *mcpServer, c *client.Client) {
+	prompts, err := c.ListPrompts(ctx, mcp.ListPromptsRequest{})
+	if err != nil {
+		rt.logger.Warn("Failed to list MCP prompts", "mcp", srv.cfg.Name, "error", err)
+		return
+	}
+	for _, p := range prompts.Prompts {
+		name := p.Name
+		p.Name = srv.cfg.Prefix + name
+		s.AddPrompt(p, func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+			request.Params.Name = name
+			return c.GetPrompt(ctx, request)
+		})
+	}
+}
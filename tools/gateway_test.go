@@ -0,0 +1,41 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGatewayLazyServer(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	cfg := McpConfig{Lazy: true, Prefix: "t_", AutoApprove: []string{"*"}}
+	startTestRuntime(t, cfg) // saves the manifest
+
+	rt := startTestRuntime(t, cfg)
+	gateway := NewGateway(context.Background(), rt)
+	handle := func(ctx context.Context, method, params string) string {
+		msg := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":` + params + `}`
+		b, _ := json.Marshal(gateway.HandleMessage(ctx, []byte(msg)))
+		return string(b)
+	}
+
+	if got := handle(context.Background(), "resources/list", "{}"); strings.Contains(got, "test://readme") {
+		t.Errorf("resources of a lazy server offered before it started: %s", got)
+	}
+	// the call's context reaches the server
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := handle(canceled, "tools/call", `{"name":"t_read","arguments":{}}`); !strings.Contains(got, "context canceled") {
+		t.Errorf("call with a canceled context = %s", got)
+	}
+	if got := handle(context.Background(), "tools/call", `{"name":"t_read","arguments":{}}`); !strings.Contains(got, "read ") {
+		t.Fatalf("call = %s", got)
+	}
+	if got := handle(context.Background(), "resources/list", "{}"); !strings.Contains(got, "test://readme") {
+		t.Errorf("resources after the lazy server started = %s", got)
+	}
+	if got := handle(context.Background(), "prompts/list", "{}"); !strings.Contains(got, `"t_greet"`) {
+		t.Errorf("prompts after the lazy server started = %s", got)
+	}
+}
@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// testServerArg makes the test binary act as MCP server, see startTestRuntime.
+// It is an argument rather than a variable, so the server also runs with an empty environment.
+const testServerArg = "__ghost-test-mcp-server"
+
+// TestMain runs the test binary as MCP server for startTestRuntime, and as
+// sandbox shim like ghost does.
+func TestMain(m *testing.M) {
+	RunSandboxShim()
+	if len(os.Args) > 1 && os.Args[1] == testServerArg {
+		serveTestMCP()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// serveTestMCP serves tools answering with their name and a call counter
+// shared by all tools, so tests can tell cached results from new calls.
+func serveTestMCP() {
+	var calls atomic.Int64
+	s := server.NewMCPServer("test", "1.0.0", server.WithToolCapabilities(true),
+		server.WithResourceCapabilities(false, false), server.WithPromptCapabilities(false))
+	counted := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(fmt.Sprintf("%s %d", req.Params.Name, calls.Add(1))), nil
+	}
+	s.AddTool(mcp.NewTool("read", mcp.WithReadOnlyHintAnnotation(true), mcp.WithString("path")), counted)
+	s.AddTool(mcp.NewTool("write", mcp.WithIdempotentHintAnnotation(true), mcp.WithString("path")), counted)
+	s.AddTool(mcp.NewTool("status"), counted)
+	s.AddTool(mcp.NewTool("touch"), counted)
+	s.AddTool(mcp.NewTool("env", mcp.WithString("name", mcp.Required())), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, _ := req.Params.Arguments["name"].(string)
+		return mcp.NewToolResultText(name + "=" + os.Getenv(name)), nil
+	})
+	// grow adds a tool, which notifies the clients that the tool list changed
+	s.AddTool(mcp.NewTool("grow"), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		s.AddTool(mcp.NewTool("grown"), counted)
+		return mcp.NewToolResultText("grown"), nil
+	})
+	s.AddTool(mcp.NewTool("fail"), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return nil, errors.New("connection lost")
+	})
+	s.AddTool(mcp.NewTool("empty"), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return &mcp.CallToolResult{Content: []mcp.Content{}}, nil
+	})
+	s.AddResource(mcp.NewResource("test://readme", "readme"), func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		return []mcp.ResourceContents{mcp.TextResourceContents{URI: req.Params.URI, Text: "read me"}}, nil
+	})
+	s.AddPrompt(mcp.NewPrompt("greet"), func(ctx context.Context, req mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		return mcp.NewGetPromptResult("greet", []mcp.PromptMessage{mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent("hello"))}), nil
+	})
+	if err := server.ServeStdio(s); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// startTestRuntime starts the test MCP server with cfg, named "test".
+func startTestRuntime(t *testing.T, cfg McpConfig) *Runtime {
+	t.Helper()
+	cfg.Name = "test"
+	cfg.Command = os.Args[0]
+	cfg.Args = append([]string{testServerArg}, cfg.Args...)
+	rt, err := InitializeMCP(context.Background(), []McpConfig{cfg}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Failures["test"]; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(rt.CloseFunc)
+	return rt
+}
+
+// callText calls a tool and returns the text of its result.
+func callText(t *testing.T, rt *Runtime, name string, arguments map[string]any) string {
+	t.Helper()
+	res, err := rt.Caller(context.Background(), name, arguments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := ""
+	for _, c := range res.Content {
+		if tc, ok := c.(mcp.TextContent); ok {
+			text += tc.Text
+		}
+	}
+	return text
+}
@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// manifestPath returns where the tool list of a lazy server is cached.
+// The file name hashes the resolved command line, environment and working
+// directory, so changing any of them invalidates the cache. Secret references
+// are hashed unresolved.
+func manifestPath(cfg McpConfig) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user cache directory: %w", err)
+	}
+	spec, err := resolveProcess(context.Background(), cfg, false)
+	if err != nil {
+		return "", err
+	}
+	dir, err := filepath.Abs(spec.dir)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	json.NewEncoder(h).Encode([]any{spec.command, spec.args, spec.env, dir, cfg.InheritEnv == nil || *cfg.InheritEnv})
+	name := fmt.Sprintf("%s-%s.json", unsafeFileChars.ReplaceAllString(cfg.Name, "_"), hex.EncodeToString(h.Sum(nil))[:16])
+	return filepath.Join(cacheDir, "ghost", "mcp-manifests", name), nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// loadManifest reads the tools cached for cfg by saveManifest.
+func loadManifest(cfg McpConfig) ([]mcp.Tool, error) {
+	p, err := manifestPath(cfg)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var listed []mcp.Tool
+	if err := json.Unmarshal(b, &listed); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest %s: %w", p, err)
+	}
+	return listed, nil
+}
+
+// saveManifest caches the tools listed by the server of cfg.
+func saveManifest(cfg McpConfig, listed []mcp.Tool) error {
+	p, err := manifestPath(cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return fmt.Errorf("failed to create manifest directory: %w", err)
+	}
+	b, err := json.Marshal(listed)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p, b, 0644)
+}
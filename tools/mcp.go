@@ -3,8 +3,10 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -18,128 +20,388 @@ import (
 type McpConfig struct {
 	Name    string
 	Command string
-	Env     []string
-	Args    []string
+	// Env holds KEY=VALUE variables for the server, on top of ghost's environment.
+	Env  []string
+	Args []string
+
+	// Command, Args, Env, EnvFile and Cwd may refer to ${VAR} or ${VAR:-default},
+	// taken from EnvFile, earlier Env entries or ghost's environment.
+
+	// Cwd is the working directory of the server, defaults to ghost's.
+	Cwd string
+	// InheritEnv passes ghost's environment to the server, defaults to true.
+	// Sandboxed servers only get Sandbox.KeepEnv.
+	InheritEnv *bool
+	// EnvFile is a dotenv file with more variables, Env overrides them.
+	// Its values are taken as they are, never as secret references.
+	EnvFile string
+
+	// InitTimeout limits the start and initialization of the server,
+	// ListTimeout each listing of its tools. Both default to 60s.
+	InitTimeout time.Duration
+	ListTimeout time.Duration
+
+	// Prefix is prepended to the server's tool and prompt names, e.g. "git_",
+	// to keep them unique across servers.
+	Prefix string
+	// Tools, if set, lists the only tools offered to the LLM.
+	Tools []string
+	// ExcludeTools lists tools hidden from the LLM.
+	ExcludeTools []string
+	// AutoApprove lists tools that run without confirmation, "*" approves all.
+	// Tool names here and in Tools/ExcludeTools are the server's, without Prefix.
+	AutoApprove []string
+
+	// Sandbox restricts the server process, Linux only.
+	Sandbox *SandboxConfig
+
+	// CacheTTL, if set, enables caching the results of the server's tools
+	// annotated read-only or idempotent, and of CacheTools, for that long,
+	// e.g. "5m". Running any other tool of any server clears the cache.
+	// Cached calls of tools that are not read-only or in CacheTools are still
+	// confirmed, unless auto-approved.
+	CacheTTL time.Duration
+	// CacheTools lists more tools without side effects whose results may be cached.
+	CacheTools []string
+
+	// Required servers abort ghost when they fail to start, others are skipped.
+	Required bool
+
+	// Lazy servers start on the first call to one of their tools. Until then
+	// their tools come from the manifest cached when the server last ran.
+	Lazy bool
+}
+
+// defaultTimeout applies to McpConfig timeouts that are not set.
+const defaultTimeout = 60 * time.Second
+
+func timeoutOr(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return defaultTimeout
+	}
+	return timeout
+}
+
+// toolEnabled reports whether the tool filters let the server's tool through.
+func (cfg McpConfig) toolEnabled(tool string) bool {
+	if len(cfg.Tools) > 0 && !slices.Contains(cfg.Tools, tool) {
+		return false
+	}
+	return !slices.Contains(cfg.ExcludeTools, tool)
+}
+
+type ToolCaller func(ctx context.Context, name string, arguments map[string]any) (*mcp.CallToolResult, error)
+
+// startServer launches the process of srv, initializes the MCP session and lists its tools.
+func (r *Runtime) startServer(ctx context.Context, srv *mcpServer) error {
+	cfg := srv.cfg
+
+	c, cmd, err := startProcess(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("Failed to create MCP client: %v", err)
+	}
+	// Client.Start wires the notification handler, which
+	// client.NewStdioMCPClient skips.
+	if err := c.Start(ctx); err != nil {
+		stopProcess(c, cmd)
+		return fmt.Errorf("Failed to create MCP client: %v", err)
+	}
+	c.OnNotification(func(n mcp.JSONRPCNotification) {
+		r.handleNotification(ctx, srv, n)
+	})
+
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{
+		Name:    "ghost-cli",
+		Version: "1.0.0",
+	}
+
+	initCtx, cancelInit := context.WithTimeout(ctx, timeoutOr(cfg.InitTimeout))
+	defer cancelInit()
+	initResult, err := c.Initialize(initCtx, initRequest)
+	if err != nil {
+		stopProcess(c, cmd)
+		return fmt.Errorf("Failed to initialize MCP client %s: %v", cfg.Name, err)
+	}
+
+	if initResult.Capabilities.Logging != nil {
+		levelRequest := mcp.SetLevelRequest{}
+		levelRequest.Params.Level = mcpLevel(ctx, r.logger)
+		if err := c.SetLevel(initCtx, levelRequest); err != nil {
+			r.logger.Warn("Failed to set MCP log level", "mcp", cfg.Name, "error", err)
+		}
+	}
+
+	toolsRequest := mcp.ListToolsRequest{}
+	listCtx, cancelList := context.WithTimeout(ctx, timeoutOr(cfg.ListTimeout))
+	defer cancelList()
+	toolsResp, err := c.ListTools(listCtx, toolsRequest)
+	if err != nil {
+		stopProcess(c, cmd)
+		return fmt.Errorf("Failed to list tools: %v", err)
+	}
+	if cfg.Lazy {
+		if err := saveManifest(cfg, toolsResp.Tools); err != nil {
+			r.logger.Warn("Failed to save MCP tool manifest", "mcp", cfg.Name, "error", err)
+		}
+	}
+
+	r.mu.Lock()
+	srv.client = c
+	srv.cmd = cmd
+	srv.setTools(toolsResp.Tools)
+	r.mu.Unlock()
+	return nil
 }
 
-type ToolCaller func(name string, arguments map[string]any) (*mcp.CallToolResult, error)
+// startedClient returns the client of srv, starting a lazy server on first use.
+func (r *Runtime) startedClient(ctx context.Context, srv *mcpServer) (*client.Client, error) {
+	srv.startMu.Lock()
+	defer srv.startMu.Unlock()
+	if c := r.clientOf(srv); c != nil {
+		return c, nil
+	}
+
+	r.logger.Info("Starting lazy MCP", "mcp", srv.cfg.Name)
+	spinner := base.StartProgressSpinner("Starting MCP "+srv.cfg.Name, 0)
+	err := r.startServer(ctx, srv)
+	spinner.Stop()
+	if err != nil {
+		return nil, err
+	}
+
+	// the tools may differ from the cached manifest
+	r.mu.Lock()
+	r.rebuildLocked()
+	onToolsChanged := r.onToolsChanged
+	r.mu.Unlock()
+	if onToolsChanged != nil {
+		onToolsChanged()
+	}
+	return r.clientOf(srv), nil
+}
 
-type Runtime struct {
-	Tools     []mcp.Tool
-	Caller    ToolCaller
-	CloseFunc func()
+// clientOf returns the client of srv, nil if it is not started.
+func (r *Runtime) clientOf(srv *mcpServer) *client.Client {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return srv.client
 }
 
 func InitializeMCP(ctx context.Context, cfgs []McpConfig, logger *slog.Logger) (*Runtime, error) {
-	mcpClients := make([]*client.Client, 0, len(cfgs))
-	toolToClient := make(map[string]*client.Client)
-	allTools := make([]mcp.Tool, 0)
+	rt := &Runtime{
+		Confirm: ConsoleConfirm,
+		logger:  logger,
+		servers: make([]*mcpServer, len(cfgs)),
+	}
 
 	spinner := base.StartProgressSpinner("Initialize MCPs", len(cfgs))
-	var mu sync.Mutex
 	var wg sync.WaitGroup
-	errChan := make(chan error, len(cfgs))
+	startErrs := make([]error, len(cfgs))
 
 	wg.Add(len(cfgs))
-	for _, mcpCfg := range cfgs {
-		go func(cfg McpConfig) {
+	for i, mcpCfg := range cfgs {
+		go func(i int, cfg McpConfig) {
 			defer wg.Done()
 			defer spinner.Incr()
 
-			c, err := client.NewStdioMCPClient(cfg.Command, cfg.Env, cfg.Args...)
-			if err != nil {
-				errChan <- fmt.Errorf("Failed to create MCP client: %v", err)
-				return
-			}
-
-			initRequest := mcp.InitializeRequest{}
-			initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
-			initRequest.Params.ClientInfo = mcp.Implementation{
-				Name:    "ghost-cli",
-				Version: "1.0.0",
+			srv := &mcpServer{cfg: cfg}
+			if cfg.Lazy {
+				// start on the first tool call, with the tools seen last time
+				if listed, err := loadManifest(cfg); err == nil {
+					rt.mu.Lock()
+					srv.setTools(listed)
+					rt.servers[i] = srv
+					rt.mu.Unlock()
+					return
+				}
+				logger.Info("No tool manifest for lazy MCP, starting it now", "mcp", cfg.Name)
 			}
 
-			initCtx, cancelInit := context.WithTimeout(ctx, 60*time.Second)
-			defer cancelInit()
-			_, err = c.Initialize(initCtx, initRequest)
-			if err != nil {
-				errChan <- fmt.Errorf("Failed to initialize MCP client %s: %v", cfg.Name, err)
-				return
-			}
-
-			toolsRequest := mcp.ListToolsRequest{}
-			toolsResp, err := c.ListTools(ctx, toolsRequest)
-			if err != nil {
-				errChan <- fmt.Errorf("Failed to list tools: %v", err)
+			if err := rt.startServer(ctx, srv); err != nil {
+				startErrs[i] = err
 				return
 			}
-
-			mu.Lock()
-			mcpClients = append(mcpClients, c)
-			for _, t := range toolsResp.Tools {
-				toolToClient[t.Name] = c
-				allTools = append(allTools, t)
-			}
-			mu.Unlock()
-		}(mcpCfg)
+			rt.mu.Lock()
+			rt.servers[i] = srv
+			rt.mu.Unlock()
+		}(i, mcpCfg)
 	}
 
 	wg.Wait()
-	close(errChan)
 
-	if len(errChan) > 0 {
-		// Return the first error encountered
-		return nil, <-errChan
+	closeFunc := func() {
+		rt.mu.RLock()
+		defer rt.mu.RUnlock()
+		for _, s := range rt.servers {
+			if s != nil && s.client != nil {
+				stopProcess(s.client, s.cmd)
+			}
+		}
 	}
 
-	closeFunc := func() {
-		for _, c := range mcpClients {
-			c.Close()
+	// Carry on without failed servers, unless they are required
+	var requiredErrs []error
+	for i, err := range startErrs {
+		if err == nil {
+			continue
 		}
+		cfg := cfgs[i]
+		logger.Error("MCP failed to start", "mcp", cfg.Name, "required", cfg.Required, "error", err)
+		if cfg.Required {
+			requiredErrs = append(requiredErrs, fmt.Errorf("required MCP %s: %w", cfg.Name, err))
+			continue
+		}
+		if rt.Failures == nil {
+			rt.Failures = make(map[string]error)
+		}
+		rt.Failures[cfg.Name] = err
+		color.Yellow("MCP %s failed to start, continuing without it: %v", cfg.Name, err)
+	}
+	if len(requiredErrs) > 0 {
+		closeFunc()
+		return nil, errors.Join(requiredErrs...)
 	}
 
-	toolCaller := func(name string, arguments map[string]any) (*mcp.CallToolResult, error) {
-		b, err := json.MarshalIndent(arguments, "", "  ")
-		if err != nil {
-			return nil, err
-		}
-
-		color.Magenta("Confirm tool call:")
-		fmt.Printf(`=======================
-Name: %s
-Arguments:
-%v
-=======================
-Press Enter to continue, or type anything else to refuse:`, name, string(b))
-		var confirm string
-		fmt.Scanln(&confirm)
-		if confirm != "" {
-			return &mcp.CallToolResult{
-				Content: []mcp.Content{mcp.TextContent{Text: "User refused tool call"}},
-			}, nil
-		}
-		c, ok := toolToClient[name]
+	rt.mu.Lock()
+	rt.rebuildLocked()
+	rt.mu.Unlock()
+
+	toolCaller := func(callCtx context.Context, name string, arguments map[string]any) (*mcp.CallToolResult, error) {
+		route, ok := rt.routeFor(name)
 		if !ok {
-			return &mcp.CallToolResult{
-				Content: []mcp.Content{mcp.TextContent{Text: "Error: tool not found in any MCP client"}}, // TODO maybe, should return error
-			}, nil
+			return mcp.NewToolResultError(fmt.Sprintf("Error: unknown tool %q, available tools: %s",
+				name, strings.Join(rt.toolNames(), ", "))), nil
+		}
+		srv, toolName := route.server, route.name
+
+		// Let the model fix bad arguments before anybody is asked to approve them
+		if schema, err := inputSchema(route.tool); err != nil {
+			logger.Warn("Failed to read tool schema", "tool", name, "error", err)
+		} else if errs := validateArguments(schema, arguments); len(errs) > 0 {
+			logger.Info("Invalid tool arguments", "tool", name, "errors", errs)
+			return mcp.NewToolResultError(fmt.Sprintf("Error: invalid arguments for tool %q:\n- %s",
+				name, strings.Join(errs, "\n- "))), nil
+		}
+
+		// Repeated calls are answered from the cache, like the approved call they
+		// repeat. Calls of tools with side effects, like idempotent writes, are
+		// still confirmed first.
+		serverTool := route.serverTool()
+		key, cacheable := cacheKey(srv.cfg.Name, toolName, arguments)
+		cacheable = cacheable && srv.cfg.cacheable(serverTool)
+		var cached *mcp.CallToolResult
+		if cacheable {
+			cached, _ = rt.cache.get(key)
+		}
+		fromCache := func() (*mcp.CallToolResult, error) {
+			logger.Debug("Tool result from cache", "tool", name)
+			fmt.Printf("Tool result of %s from cache\n", name)
+			return cached, nil
+		}
+		if cached != nil && (srv.cfg.sideEffectFree(serverTool) || srv.autoApproved(toolName)) {
+			return fromCache()
+		}
+
+		editedArguments := false
+		if !srv.autoApproved(toolName) {
+			decision, err := rt.Confirm(ToolCall{
+				Name:      name,
+				Server:    srv.cfg.Name,
+				Tool:      serverTool,
+				Arguments: arguments,
+				Dir:       srv.cfg.workDir(),
+			})
+			if err != nil {
+				return nil, err
+			}
+			if !decision.Approved {
+				text := "User refused tool call"
+				if decision.Reason != "" {
+					text += ": " + rt.Redactor.Redact(decision.Reason)
+				}
+				logger.Info("Tool call refused", "tool", name, "reason", decision.Reason)
+				return &mcp.CallToolResult{
+					Content: []mcp.Content{mcp.TextContent{Text: text}},
+				}, nil
+			}
+			if decision.Arguments != nil {
+				logger.Info("Tool arguments edited by user", "tool", name)
+				arguments, editedArguments = decision.Arguments, true
+			}
+		}
+		if cached != nil && !editedArguments {
+			return fromCache()
+		}
+		// Failures from here on are results the LLM can react to
+		// lazy servers live as long as the runtime, not the call that starts them
+		c, err := rt.startedClient(ctx, srv)
+		if err != nil {
+			logger.Error("Failed to start MCP for tool call", "tool", name, "error", err)
+			return mcp.NewToolResultError(fmt.Sprintf("Error: tool %s is unavailable: %v", name, err)), nil
 		}
 		callReq := mcp.CallToolRequest{
 			Request: mcp.Request{
 				Method: "tools/call",
 			},
 		}
-		callReq.Params.Name = name
+		callReq.Params.Name = toolName
 		callReq.Params.Arguments = arguments
-		callResult, err := c.CallTool(ctx, callReq)
+
+		spinner := base.StartProgressSpinner("Running "+name, 0)
+		token, untrack := rt.trackProgress(spinner)
+		callReq.Params.Meta = &struct {
+			ProgressToken mcp.ProgressToken `json:"progressToken,omitempty"`
+		}{ProgressToken: token}
+		callResult, err := c.CallTool(callCtx, callReq)
+		untrack()
+		spinner.Stop()
+		// even a failed call, e.g. one that timed out, may have changed something
+		if !srv.cfg.sideEffectFree(serverTool) {
+			rt.cache.clear()
+		}
 		if err != nil {
-			return callResult, err
+			logger.Error("Tool call failed", "tool", name, "error", err)
+			return mcp.NewToolResultError(fmt.Sprintf("Error: tool %s failed: %v", name, rt.Redactor.Redact(err.Error()))), nil
+		}
+		if callResult.IsError {
+			logger.Info("Tool returned an error", "tool", name)
+		}
+		for i, content := range callResult.Content {
+			if tc, ok := content.(mcp.TextContent); ok {
+				tc.Text = rt.Redactor.Redact(tc.Text)
+				callResult.Content[i] = tc
+			}
+		}
+		if editedArguments {
+			// tell the LLM, or it takes the result for the one of its own arguments
+			b, _ := json.Marshal(arguments)
+			note := fmt.Sprintf("Note: the user edited the arguments, the tool ran with %s\n\n", rt.Redactor.Redact(string(b)))
+			tc, ok := mcp.TextContent{}, false
+			if len(callResult.Content) > 0 {
+				tc, ok = callResult.Content[0].(mcp.TextContent)
+			}
+			if ok {
+				tc.Text = note + tc.Text
+				callResult.Content[0] = tc
+			} else {
+				callResult.Content = append([]mcp.Content{mcp.NewTextContent(note)}, callResult.Content...)
+			}
+		}
+		if cacheable && !editedArguments && !callResult.IsError {
+			rt.cache.put(key, callResult, srv.cfg.CacheTTL)
+		}
+		var texts []string // servers may return no content, or no text
+		for _, content := range callResult.Content {
+			if tc, ok := content.(mcp.TextContent); ok {
+				texts = append(texts, tc.Text)
+			}
 		}
-		tc, _ := callResult.Content[0].(mcp.TextContent)
-		logger.Debug("Tool result", "result", tc.Text)
+		logger.Debug("Tool result", "result", strings.Join(texts, "\n"))
 
 		{ // print tool call result, (TODO move to base pkg PrintInfoSummary)
-			text := tc.Text
+			text := strings.Join(texts, "\n")
 			textLen := len(text)
 			words := strings.Fields(text) // Split into words
 			wordCount := len(words)
@@ -162,9 +424,7 @@ Press Enter to continue, or type anything else to refuse:`, name, string(b))
 		return callResult, nil
 	}
 
-	return &Runtime{
-		Tools:     allTools,
-		Caller:    toolCaller,
-		CloseFunc: closeFunc,
-	}, nil
+	rt.Caller = toolCaller
+	rt.CloseFunc = closeFunc
+	return rt, nil
 }
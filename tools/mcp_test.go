@@ -0,0 +1,52 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEmptyToolResult(t *testing.T) {
+	rt := startTestRuntime(t, McpConfig{AutoApprove: []string{"*"}})
+	res, err := rt.Caller(context.Background(), "empty", nil)
+	if err != nil || res.IsError || len(res.Content) != 0 {
+		t.Errorf("empty result = %+v, %v", res, err)
+	}
+}
+
+func TestLazyStartConcurrent(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	cfg := McpConfig{Lazy: true, AutoApprove: []string{"*"}}
+	startTestRuntime(t, cfg) // no manifest yet, so it starts and saves one
+
+	rt := startTestRuntime(t, cfg)
+	if rt.clientOf(rt.servers[0]) != nil {
+		t.Fatal("lazy server started although its manifest was saved")
+	}
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			switch i % 3 {
+			case 0:
+				callText(t, rt, "read", map[string]any{"path": fmt.Sprint(i)})
+			case 1:
+				rt.Ping(context.Background())
+			default:
+				// gateways built while and after the server starts
+				for range 20 {
+					gateway := NewGateway(context.Background(), rt)
+					gateway.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"read","arguments":{}}}`))
+					time.Sleep(time.Millisecond)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if rt.clientOf(rt.servers[0]) == nil {
+		t.Error("lazy server not started by the calls")
+	}
+}
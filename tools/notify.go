@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// handleNotification processes a notification sent by server s.
+func (r *Runtime) handleNotification(ctx context.Context, s *mcpServer, n mcp.JSONRPCNotification) {
+	switch n.Method {
+	case mcp.MethodNotificationToolsListChanged:
+		// The handler runs on the transport's read loop, which the
+		// list request has to wait on, so re-list asynchronously.
+		go func() {
+			if err := r.refreshTools(ctx, s); err != nil {
+				r.logger.Warn("Failed to refresh MCP tools", "mcp", s.cfg.Name, "error", err)
+			}
+		}()
+
+	case "notifications/progress":
+		var progress mcp.ProgressNotification
+		if err := remarshal(n.Params.AdditionalFields, &progress.Params); err != nil {
+			r.logger.Warn("Bad MCP progress notification", "mcp", s.cfg.Name, "error", err)
+			return
+		}
+		r.progressMu.Lock()
+		spinner, ok := r.progressShown[progress.Params.ProgressToken]
+		r.progressMu.Unlock()
+		if !ok {
+			return
+		}
+		p := progress.Params
+		text := fmt.Sprintf("%g", p.Progress)
+		if p.Total > 0 {
+			text = fmt.Sprintf("%g/%g", p.Progress, p.Total)
+		}
+		if p.Message != "" {
+			text += " " + p.Message
+		}
+		spinner.SetMessage(text)
+
+	case "notifications/message":
+		var logMsg mcp.LoggingMessageNotification
+		if err := remarshal(n.Params.AdditionalFields, &logMsg.Params); err != nil {
+			r.logger.Warn("Bad MCP log notification", "mcp", s.cfg.Name, "error", err)
+			return
+		}
+		r.logger.Log(ctx, slogLevel(logMsg.Params.Level), "MCP log",
+			"mcp", s.cfg.Name, "logger", logMsg.Params.Logger, "data", logMsg.Params.Data)
+	}
+}
+
+// progressView shows the progress of a tool call, like a *base.Spinner.
+type progressView interface {
+	SetMessage(message string)
+}
+
+// trackProgress returns a new progress token whose notifications are shown on spinner,
+// and a func to stop tracking it.
+func (r *Runtime) trackProgress(spinner progressView) (mcp.ProgressToken, func()) {
+	r.progressMu.Lock()
+	defer r.progressMu.Unlock()
+	if r.progressShown == nil {
+		r.progressShown = make(map[mcp.ProgressToken]progressView)
+	}
+	r.progressSeq++
+	// a string token survives the JSON round trip unchanged, numbers come back as float64
+	token := mcp.ProgressToken(fmt.Sprintf("ghost-%d", r.progressSeq))
+	r.progressShown[token] = spinner
+	return token, func() {
+		r.progressMu.Lock()
+		delete(r.progressShown, token)
+		r.progressMu.Unlock()
+	}
+}
+
+// slogLevel maps an MCP (syslog) log level to the closest slog level.
+func slogLevel(level mcp.LoggingLevel) slog.Level {
+	switch level {
+	case mcp.LoggingLevelDebug:
+		return slog.LevelDebug
+	case mcp.LoggingLevelInfo, mcp.LoggingLevelNotice:
+		return slog.LevelInfo
+	case mcp.LoggingLevelWarning:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+// mcpLevel returns the lowest MCP log level the logger records.
+func mcpLevel(ctx context.Context, logger *slog.Logger) mcp.LoggingLevel {
+	switch {
+	case logger.Enabled(ctx, slog.LevelDebug):
+		return mcp.LoggingLevelDebug
+	case logger.Enabled(ctx, slog.LevelInfo):
+		return mcp.LoggingLevelInfo
+	case logger.Enabled(ctx, slog.LevelWarn):
+		return mcp.LoggingLevelWarning
+	default:
+		return mcp.LoggingLevelError
+	}
+}
+
+// remarshal converts notification params into their typed form.
+func remarshal(from map[string]any, to any) error {
+	b, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, to)
+}
@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// fakeSpinner records the progress messages shown on it.
+type fakeSpinner struct{ messages []string }
+
+func (f *fakeSpinner) SetMessage(message string) { f.messages = append(f.messages, message) }
+
+func notification(method string, params map[string]any) mcp.JSONRPCNotification {
+	return mcp.JSONRPCNotification{Notification: mcp.Notification{
+		Method: method,
+		Params: mcp.NotificationParams{AdditionalFields: params},
+	}}
+}
+
+func TestProgressNotification(t *testing.T) {
+	rt := &Runtime{logger: slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))}
+	srv := &mcpServer{cfg: McpConfig{Name: "test"}}
+	first, second := &fakeSpinner{}, &fakeSpinner{}
+	firstToken, untrackFirst := rt.trackProgress(first)
+	secondToken, untrackSecond := rt.trackProgress(second)
+	defer untrackSecond()
+	if firstToken == secondToken {
+		t.Fatalf("tokens not unique: %v", firstToken)
+	}
+
+	progress := func(token mcp.ProgressToken, params map[string]any) {
+		params["progressToken"] = token
+		rt.handleNotification(context.Background(), srv, notification("notifications/progress", params))
+	}
+	progress(secondToken, map[string]any{"progress": 3, "total": 10, "message": "files"})
+	progress(firstToken, map[string]any{"progress": 1})
+	progress("unknown", map[string]any{"progress": 5})
+	untrackFirst()
+	progress(firstToken, map[string]any{"progress": 2})
+
+	if got := strings.Join(first.messages, "|"); got != "1" {
+		t.Errorf("first spinner = %q, want only the progress sent while tracked", got)
+	}
+	if got := strings.Join(second.messages, "|"); got != "3/10 files" {
+		t.Errorf("second spinner = %q, want %q", got, "3/10 files")
+	}
+}
+
+func TestLogNotification(t *testing.T) {
+	var buf bytes.Buffer
+	rt := &Runtime{logger: slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))}
+	srv := &mcpServer{cfg: McpConfig{Name: "test"}}
+	rt.handleNotification(context.Background(), srv, notification("notifications/message",
+		map[string]any{"level": "warning", "logger": "db", "data": "slow query"}))
+	if got := buf.String(); !strings.Contains(got, "level=WARN") || !strings.Contains(got, "mcp=test") ||
+		!strings.Contains(got, "logger=db") || !strings.Contains(got, `data="slow query"`) {
+		t.Errorf("log = %s", got)
+	}
+
+	for level, want := range map[mcp.LoggingLevel]slog.Level{
+		mcp.LoggingLevelDebug:     slog.LevelDebug,
+		mcp.LoggingLevelInfo:      slog.LevelInfo,
+		mcp.LoggingLevelNotice:    slog.LevelInfo,
+		mcp.LoggingLevelWarning:   slog.LevelWarn,
+		mcp.LoggingLevelError:     slog.LevelError,
+		mcp.LoggingLevelCritical:  slog.LevelError,
+		mcp.LoggingLevelAlert:     slog.LevelError,
+		mcp.LoggingLevelEmergency: slog.LevelError,
+	} {
+		if got := slogLevel(level); got != want {
+			t.Errorf("slogLevel(%s) = %v, want %v", level, got, want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// PingResult is the outcome of pinging an MCP server.
+type PingResult struct {
+	Name    string
+	Tools   int // offered tools, after the config's filters
+	Latency time.Duration
+	Err     error
+}
+
+// Ping checks that every server answers, starting lazy ones. Servers that
+// failed to start are reported last, with their start error.
+func (r *Runtime) Ping(ctx context.Context) []PingResult {
+	r.mu.RLock()
+	servers := slices.Clone(r.servers)
+	r.mu.RUnlock()
+
+	var results []PingResult
+	for _, s := range servers {
+		if s == nil {
+			continue
+		}
+		res := PingResult{Name: s.cfg.Name}
+		start := time.Now()
+		c, err := r.startedClient(ctx, s)
+		if err == nil {
+			pingCtx, cancel := context.WithTimeout(ctx, timeoutOr(s.cfg.ListTimeout))
+			err = c.Ping(pingCtx)
+			cancel()
+		}
+		res.Latency, res.Err = time.Since(start), err
+		r.mu.RLock()
+		res.Tools = len(s.tools)
+		r.mu.RUnlock()
+		results = append(results, res)
+	}
+	for _, name := range slices.Sorted(maps.Keys(r.Failures)) {
+		results = append(results, PingResult{Name: name, Err: r.Failures[name]})
+	}
+	return results
+}
+
+// ServerTool is an offered tool together with the server it belongs to.
+type ServerTool struct {
+	Server string
+	Tool   mcp.Tool
+}
+
+// ServerTools returns the offered tools with their servers, in the order of Tools.
+func (r *Runtime) ServerTools() []ServerTool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]ServerTool, 0, len(r.tools))
+	for _, t := range r.tools {
+		out = append(out, ServerTool{Server: r.routes[t.Name].server.cfg.Name, Tool: t})
+	}
+	return out
+}
@@ -0,0 +1,263 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/fatih/color"
+	"github.com/kk2simon/ghost-cli/base"
+)
+
+// previewRenderer renders the arguments of a call for the approval prompt,
+// or returns false to fall back to the JSON arguments.
+type previewRenderer func(call ToolCall) (string, bool)
+
+// previewRenderers are tried in order, by the server's tool name or, for
+// tools with other names, by annotations and argument shape.
+var previewRenderers = []struct {
+	match  func(call ToolCall) bool
+	render previewRenderer
+}{
+	{func(call ToolCall) bool { return call.Tool.Name == "edit_file" }, renderEditFile},
+	{isFileWrite, renderWriteFile},
+	{isShellCommand, renderShellCommand},
+}
+
+// renderPreview returns the preview of call shown for approval.
+func renderPreview(call ToolCall) string {
+	for _, r := range previewRenderers {
+		if r.match(call) {
+			if preview, ok := r.render(call); ok {
+				return preview
+			}
+		}
+	}
+	return argumentsJSON(call)
+}
+
+// argumentsJSON returns the arguments of call as indented JSON.
+func argumentsJSON(call ToolCall) string {
+	b, err := json.MarshalIndent(call.Arguments, "", "  ")
+	if err != nil {
+		return fmt.Sprint(call.Arguments)
+	}
+	return string(b)
+}
+
+func isFileWrite(call ToolCall) bool {
+	if call.Tool.Name == "write_file" {
+		return true
+	}
+	// any tool which may modify its environment and takes a path and content
+	_, hasContent := call.Arguments["content"].(string)
+	return !readOnly(call.Tool) && hasContent && argPath(call) != ""
+}
+
+func isShellCommand(call ToolCall) bool {
+	if _, ok := call.Arguments["command"]; !ok {
+		return false
+	}
+	for _, word := range nameWords(call.Tool.Name) {
+		if slices.Contains([]string{"shell", "bash", "sh", "exec", "execute", "command", "terminal", "run"}, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// nameWords splits a tool name like "run_command" or "execCommand" into its
+// lower-case words.
+func nameWords(name string) []string {
+	var words []string
+	start := 0
+	for i, r := range name {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			if i > start {
+				words = append(words, name[start:i])
+			}
+			start = i + 1
+		case unicode.IsUpper(r) && i > start && unicode.IsLower(rune(name[i-1])):
+			words = append(words, name[start:i])
+			start = i
+		}
+	}
+	if start < len(name) {
+		words = append(words, name[start:])
+	}
+	for i := range words {
+		words[i] = strings.ToLower(words[i])
+	}
+	return words
+}
+
+// argPath returns the file path argument of call, relative paths made
+// relative to the server's working directory.
+func argPath(call ToolCall) string {
+	for _, key := range []string{"path", "file_path", "filePath", "filename"} {
+		if p, ok := call.Arguments[key].(string); ok && p != "" {
+			if !filepath.IsAbs(p) && call.Dir != "" {
+				p = filepath.Join(call.Dir, p)
+			}
+			return p
+		}
+	}
+	return ""
+}
+
+// renderWriteFile shows the change of a file write as a diff against the current contents.
+func renderWriteFile(call ToolCall) (string, bool) {
+	path := argPath(call)
+	content, ok := call.Arguments["content"].(string)
+	if !ok {
+		return "", false
+	}
+	return fileDiff(path, content), true
+}
+
+// renderEditFile applies the edits of the filesystem server's edit_file
+// ({path, edits: [{oldText, newText}]}) to the current contents and shows the diff.
+// If an edit doesn't apply, a partial diff would mislead, so the arguments are shown.
+func renderEditFile(call ToolCall) (string, bool) {
+	path := argPath(call)
+	edits, ok := call.Arguments["edits"].([]any)
+	if path == "" || !ok {
+		return "", false
+	}
+	current, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+
+	content := string(current)
+	for i, e := range edits {
+		edit, _ := e.(map[string]any)
+		oldText, _ := edit["oldText"].(string)
+		newText, _ := edit["newText"].(string)
+		if oldText == "" || !strings.Contains(content, oldText) {
+			warning := color.YellowString("edit %d: oldText not found verbatim in %s, the tool will likely fail. Arguments:", i+1, path)
+			return warning + "\n" + argumentsJSON(call), true
+		}
+		content = strings.Replace(content, oldText, newText, 1)
+	}
+	return fileDiff(path, content), true
+}
+
+// fileDiff returns a colored diff between the file at path and content.
+func fileDiff(path, content string) string {
+	var sb strings.Builder
+	current, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(&sb, "%s\n", color.YellowString("%s does not exist or can't be read (%v), new contents:", path, err))
+	}
+	diff := base.UnifiedDiff(path, path+" (after)", string(current), content)
+	if diff == "" {
+		sb.WriteString("(no changes)\n")
+	}
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			sb.WriteString(color.New(color.Bold).Sprint(line))
+		case strings.HasPrefix(line, "@@"):
+			sb.WriteString(color.CyanString(line))
+		case strings.HasPrefix(line, "+"):
+			sb.WriteString(color.GreenString(line))
+		case strings.HasPrefix(line, "-"):
+			sb.WriteString(color.RedString(line))
+		default:
+			sb.WriteString(line)
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// renderShellCommand shows a command with simple shell highlighting, and any other arguments below.
+func renderShellCommand(call ToolCall) (string, bool) {
+	var command string
+	switch c := call.Arguments["command"].(type) {
+	case string:
+		command = c
+	case []any:
+		parts := make([]string, 0, len(c))
+		for _, p := range c {
+			parts = append(parts, fmt.Sprint(p))
+		}
+		command = strings.Join(parts, " ")
+	default:
+		return "", false
+	}
+
+	var sb strings.Builder
+	sb.WriteString("$ " + highlightShell(command))
+	for _, key := range slices.Sorted(maps.Keys(call.Arguments)) {
+		if key != "command" {
+			fmt.Fprintf(&sb, "\n%s: %v", key, call.Arguments[key])
+		}
+	}
+	return sb.String(), true
+}
+
+// highlightShell colors programs, flags, quoted strings and operators of a command line.
+func highlightShell(command string) string {
+	var sb strings.Builder
+	expectProgram := true
+	for _, tok := range shellTokens(command) {
+		switch {
+		case strings.TrimSpace(tok) == "":
+			sb.WriteString(tok)
+		case strings.ContainsAny(tok[:1], `|&;<>`):
+			sb.WriteString(color.MagentaString(tok))
+			expectProgram = strings.ContainsAny(tok, "|&;")
+		case tok[0] == '"' || tok[0] == '\'':
+			sb.WriteString(color.GreenString(tok))
+		case expectProgram:
+			sb.WriteString(color.New(color.Bold, color.FgCyan).Sprint(tok))
+			expectProgram = false
+		case tok[0] == '-':
+			sb.WriteString(color.YellowString(tok))
+		default:
+			sb.WriteString(tok)
+		}
+	}
+	return sb.String()
+}
+
+// shellTokens splits a command line into words, quoted strings, operators and
+// the whitespace between them, so that joining them gives the command back.
+func shellTokens(command string) []string {
+	var tokens []string
+	for i := 0; i < len(command); {
+		j := i + 1
+		switch c := command[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			for j < len(command) && strings.IndexByte(" \t\n", command[j]) >= 0 {
+				j++
+			}
+		case c == '"' || c == '\'':
+			for j < len(command) && command[j] != c {
+				if c == '"' && command[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j = min(j+1, len(command))
+		case strings.IndexByte("|&;<>", c) >= 0:
+			for j < len(command) && strings.IndexByte("|&;<>", command[j]) >= 0 {
+				j++
+			}
+		default:
+			for j < len(command) && strings.IndexByte(" \t\n|&;<>\"'", command[j]) < 0 {
+				j++
+			}
+		}
+		tokens = append(tokens, command[i:j])
+		i = j
+	}
+	return tokens
+}
@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestIsShellCommand(t *testing.T) {
+	tests := map[string]bool{
+		"run_command":   true,
+		"execCommand":   true,
+		"shell":         true,
+		"bash-exec":     true,
+		"RunTerminal":   true,
+		"truncate":      false,
+		"prune_runs":    false,
+		"executor_list": false,
+		"git_commit":    false,
+	}
+	for name, want := range tests {
+		call := ToolCall{Tool: mcp.NewTool(name), Arguments: map[string]any{"command": "ls"}}
+		if got := isShellCommand(call); got != want {
+			t.Errorf("isShellCommand(%s) = %v, want %v (words %q)", name, got, want, nameWords(name))
+		}
+	}
+	if isShellCommand(ToolCall{Tool: mcp.NewTool("run"), Arguments: map[string]any{"script": "ls"}}) {
+		t.Error("isShellCommand without command argument")
+	}
+	if got := nameWords("git.execCommand_v2"); !slices.Equal(got, []string{"git", "exec", "command", "v2"}) {
+		t.Errorf("nameWords() = %q", got)
+	}
+}
+
+func TestFileDiffRelativePath(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("old line\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	write := ToolCall{
+		Tool:      mcp.NewTool("write_file"),
+		Arguments: map[string]any{"path": "notes.txt", "content": "new line\n"},
+		Dir:       dir,
+	}
+	preview := renderPreview(write)
+	if strings.Contains(preview, "does not exist") || !strings.Contains(preview, "-old line") || !strings.Contains(preview, "+new line") {
+		t.Errorf("write_file preview:\n%s", preview)
+	}
+
+	edit := ToolCall{
+		Tool:      mcp.NewTool("edit_file"),
+		Arguments: map[string]any{"path": "notes.txt", "edits": []any{map[string]any{"oldText": "old", "newText": "edited"}}},
+		Dir:       dir,
+	}
+	if preview := renderPreview(edit); !strings.Contains(preview, "+edited line") {
+		t.Errorf("edit_file preview:\n%s", preview)
+	}
+
+	// an edit that doesn't apply shows the arguments, not a partial diff
+	edit.Arguments["edits"] = append(edit.Arguments["edits"].([]any), map[string]any{"oldText": "missing", "newText": "x"})
+	preview = renderPreview(edit)
+	if !strings.Contains(preview, "edit 2: oldText not found") || !strings.Contains(preview, `"oldText": "missing"`) ||
+		strings.Contains(preview, "+edited line") || strings.Contains(preview, "(no changes)") {
+		t.Errorf("edit_file preview with an edit that doesn't apply:\n%s", preview)
+	}
+}
@@ -0,0 +1,233 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/kk2simon/ghost-cli/base"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+)
+
+// startProcess launches the stdio server of cfg, sandboxed if configured,
+// and returns an unstarted client talking to it.
+// ghost spawns the process itself, since transport.Stdio has no way to set
+// the working directory, environment or limits.
+func startProcess(ctx context.Context, cfg McpConfig) (*client.Client, *exec.Cmd, error) {
+	spec, err := resolveProcess(ctx, cfg, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cmd *exec.Cmd
+	if cfg.Sandbox != nil {
+		cmd, err = sandboxCommand(ctx, cfg, spec)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		cmd = exec.CommandContext(ctx, spec.command, spec.args...)
+		cmd.Dir = spec.dir
+		cmd.Env = append([]string{}, spec.env...) // never nil, which would inherit ghost's environment
+		if cfg.InheritEnv == nil || *cfg.InheritEnv {
+			cmd.Env = append(os.Environ(), spec.env...)
+		}
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("failed to start command: %w", err)
+	}
+
+	return client.NewClient(transport.NewIO(stdout, stdin, stderr)), cmd, nil
+}
+
+// stopProcess closes the client of a process started by startProcess and waits for it to exit.
+func stopProcess(c *client.Client, cmd *exec.Cmd) {
+	c.Close() // closes stdin, which makes well-behaved servers exit
+	cmd.Wait()
+}
+
+// processSpec is the command line, extra environment and working directory
+// of a server, with ${VAR} references expanded.
+type processSpec struct {
+	command string
+	args    []string
+	env     []string // EnvFile, then Env
+	dir     string
+}
+
+// resolveProcess reads the EnvFile of cfg and expands ${VAR} and ${VAR:-default}
+// in its Command, Args, Env, EnvFile and Cwd. With secrets, Env values, but not
+// those of EnvFile, may be secret references like "env:NAME" or "cmd:COMMAND",
+// see base.ResolveSecret; without, they are left unresolved.
+// Variables come from EnvFile and the preceding Env entries first, then from
+// ghost's environment.
+func resolveProcess(ctx context.Context, cfg McpConfig, secrets bool) (processSpec, error) {
+	vars := map[string]string{}
+	lookup := func(name string) (string, bool) {
+		if v, ok := vars[name]; ok {
+			return v, true
+		}
+		return os.LookupEnv(name)
+	}
+	var errs []error
+	expand := func(s string) string {
+		out, err := expandVars(s, lookup)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return out
+	}
+
+	var spec processSpec
+	addEnv := func(kv string, secrets bool) {
+		name, value, _ := strings.Cut(kv, "=")
+		value = expand(value)
+		if secrets && base.IsSecretRef(value) {
+			secret, err := base.ResolveSecret(ctx, value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("Env %s: %w", name, err))
+			}
+			value = secret
+		}
+		vars[name] = value
+		spec.env = append(spec.env, name+"="+value)
+	}
+	if cfg.EnvFile != "" {
+		lines, err := readEnvFile(expand(cfg.EnvFile))
+		if err != nil {
+			return spec, fmt.Errorf("MCP %s: %w", cfg.Name, err)
+		}
+		for _, kv := range lines {
+			addEnv(kv, false) // plain dotenv values, like DATABASE_URL=file:data.db
+		}
+	}
+	for _, kv := range cfg.Env {
+		addEnv(kv, secrets)
+	}
+
+	spec.command = expand(cfg.Command)
+	for _, arg := range cfg.Args {
+		spec.args = append(spec.args, expand(arg))
+	}
+	spec.dir = expand(cfg.Cwd)
+	if err := errors.Join(errs...); err != nil {
+		return spec, fmt.Errorf("MCP %s: %w", cfg.Name, err)
+	}
+	return spec, nil
+}
+
+// serverDir returns the absolute working directory of the server of cfg:
+// Sandbox.Dir, Cwd or ghost's.
+func serverDir(cfg McpConfig, spec processSpec) (string, error) {
+	dir := spec.dir
+	if cfg.Sandbox != nil && cfg.Sandbox.Dir != "" {
+		var err error
+		if dir, err = expandVars(cfg.Sandbox.Dir, os.LookupEnv); err != nil {
+			return "", fmt.Errorf("MCP %s Sandbox.Dir: %w", cfg.Name, err)
+		}
+	}
+	return filepath.Abs(dir) // ghost's working directory if dir is ""
+}
+
+// workDir returns the working directory of the server of cfg, or "" for
+// ghost's if it can't be resolved.
+func (cfg McpConfig) workDir() string {
+	spec, err := resolveProcess(context.Background(), cfg, false)
+	if err != nil {
+		return ""
+	}
+	dir, err := serverDir(cfg, spec)
+	if err != nil {
+		return ""
+	}
+	return dir
+}
+
+// CommandPath returns the executable the server of cfg would be started with.
+// Unlike starting it, it doesn't resolve secrets, so it can check configs.
+func (cfg McpConfig) CommandPath() (string, error) {
+	spec, err := resolveProcess(context.Background(), cfg, false)
+	if err != nil {
+		return "", err
+	}
+	command := spec.command
+	if !strings.ContainsRune(command, filepath.Separator) {
+		return exec.LookPath(command)
+	}
+	if !filepath.IsAbs(command) {
+		command = filepath.Join(spec.dir, command) // as exec.Cmd does
+	}
+	if _, err := os.Stat(command); err != nil {
+		return "", err
+	}
+	return command, nil
+}
+
+var varRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandVars replaces ${VAR} and ${VAR:-default} in s. Unset variables
+// without a default are an error.
+func expandVars(s string, lookup func(string) (string, bool)) (string, error) {
+	var missing []string
+	out := varRef.ReplaceAllStringFunc(s, func(ref string) string {
+		m := varRef.FindStringSubmatch(ref)
+		if v, ok := lookup(m[1]); ok && (v != "" || m[2] == "") {
+			return v
+		}
+		if m[2] != "" {
+			return m[3]
+		}
+		missing = append(missing, m[1])
+		return ""
+	})
+	if len(missing) > 0 {
+		return out, fmt.Errorf("variable %s is not set, use ${%s:-default} for a default", missing[0], missing[0])
+	}
+	return out, nil
+}
+
+// readEnvFile reads KEY=VALUE lines of a dotenv file. Blank lines, # comments,
+// a leading "export " and quotes around values are ignored.
+func readEnvFile(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read EnvFile: %w", err)
+	}
+	var env []string
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, i+1)
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env = append(env, name+"="+value)
+	}
+	return env, nil
+}
@@ -0,0 +1,239 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExpandVars(t *testing.T) {
+	vars := map[string]string{"HOME": "/home/me", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"plain $HOME", "plain $HOME", false},
+		{"${HOME}/bin", "/home/me/bin", false},
+		{"${UNSET:-/opt}/bin", "/opt/bin", false},
+		{"${HOME:-/opt}", "/home/me", false},
+		{"${EMPTY:-fallback}", "fallback", false},
+		{"${EMPTY}", "", false},
+		{"${UNSET:-}", "", false},
+		{"${UNSET}/bin", "/bin", true},
+		{"${HOME}${HOME}", "/home/me/home/me", false},
+	}
+	for _, tt := range tests {
+		got, err := expandVars(tt.in, lookup)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("expandVars(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestReadEnvFile(t *testing.T) {
+	tests := []struct {
+		file    string
+		want    []string
+		wantErr bool
+	}{
+		{"A=1\nB = two \n", []string{"A=1", "B=two"}, false},
+		{"# comment\n\n  export PATH_EXTRA=/opt\n", []string{"PATH_EXTRA=/opt"}, false},
+		{`A="quoted value"` + "\n" + `B='single'` + "\n" + `C="unbalanced'`, []string{"A=quoted value", "B=single", `C="unbalanced'`}, false},
+		{"URL=postgres://u:p@h/db?x=1", []string{"URL=postgres://u:p@h/db?x=1"}, false},
+		{"EMPTY=\n", []string{"EMPTY="}, false},
+		{"A=1\nnot a pair\n", nil, true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), ".env")
+		if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readEnvFile(path)
+		if !slices.Equal(got, tt.want) || (err != nil) != tt.wantErr {
+			t.Errorf("readEnvFile(%q) = %q, %v, want %q", tt.file, got, err, tt.want)
+		}
+	}
+	if _, err := readEnvFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("readEnvFile of a missing file succeeded")
+	}
+}
+
+func TestResolveProcess(t *testing.T) {
+	t.Setenv("GHOST_TEST_ROOT", "/srv")
+	envFile := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(envFile, []byte("DB=app.db\nLEVEL=debug\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     McpConfig
+		want    processSpec
+		wantErr string
+	}{
+		{
+			name: "ghost's environment",
+			cfg:  McpConfig{Command: "${GHOST_TEST_ROOT}/bin/mcp", Args: []string{"--root", "${GHOST_TEST_ROOT}"}, Cwd: "${GHOST_TEST_ROOT}/work"},
+			want: processSpec{command: "/srv/bin/mcp", args: []string{"--root", "/srv"}, dir: "/srv/work"},
+		},
+		{
+			name: "EnvFile then Env, later entries see earlier ones",
+			cfg: McpConfig{Command: "mcp", Args: []string{"--db", "${DB}"}, EnvFile: envFile,
+				Env: []string{"LEVEL=${LEVEL:-info}-x", "URL=sqlite://${DB}"}},
+			want: processSpec{command: "mcp", args: []string{"--db", "app.db"},
+				env: []string{"DB=app.db", "LEVEL=debug", "LEVEL=debug-x", "URL=sqlite://app.db"}},
+		},
+		{
+			name:    "unset variable",
+			cfg:     McpConfig{Name: "x", Command: "mcp", Args: []string{"${GHOST_TEST_UNSET}"}},
+			wantErr: "variable GHOST_TEST_UNSET is not set",
+		},
+		{
+			name:    "missing EnvFile",
+			cfg:     McpConfig{Name: "x", Command: "mcp", EnvFile: filepath.Join(t.TempDir(), "missing")},
+			wantErr: "failed to read EnvFile",
+		},
+	}
+	for _, tt := range tests {
+		got, err := resolveProcess(context.Background(), tt.cfg, false)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got.command != tt.want.command || !slices.Equal(got.args, tt.want.args) ||
+			!slices.Equal(got.env, tt.want.env) || got.dir != tt.want.dir {
+			t.Errorf("%s: resolveProcess() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestManifestPath(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("GHOST_TEST_ROOT", "/srv")
+	envFile := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(envFile, []byte("A=1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	noInherit := false
+
+	orig := McpConfig{Name: "../fs server", Command: "mcp", Args: []string{"${GHOST_TEST_ROOT}"}}
+	origPath, err := manifestPath(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := filepath.Base(origPath); !strings.HasPrefix(name, ".._fs_server-") || filepath.Dir(origPath) != filepath.Join(os.Getenv("XDG_CACHE_HOME"), "ghost", "mcp-manifests") {
+		t.Errorf("manifestPath() = %s, want sanitized name in the cache dir", origPath)
+	}
+
+	changes := map[string]func(c *McpConfig){
+		"Cwd":        func(c *McpConfig) { c.Cwd = "/elsewhere" },
+		"EnvFile":    func(c *McpConfig) { c.EnvFile = envFile },
+		"Env":        func(c *McpConfig) { c.Env = []string{"A=1"} },
+		"InheritEnv": func(c *McpConfig) { c.InheritEnv = &noInherit },
+		"Args":       func(c *McpConfig) { c.Args = []string{"/srv", "-v"} },
+	}
+	for field, change := range changes {
+		cfg := orig
+		change(&cfg)
+		if p, err := manifestPath(cfg); err != nil || p == origPath {
+			t.Errorf("changing %s kept manifest %s, %v", field, p, err)
+		}
+	}
+
+	t.Setenv("GHOST_TEST_ROOT", "/other")
+	if p, _ := manifestPath(orig); p == origPath {
+		t.Error("changing an expanded variable kept the manifest")
+	}
+	t.Setenv("GHOST_TEST_ROOT", "/srv")
+	if p, _ := manifestPath(orig); p != origPath {
+		t.Errorf("manifestPath() = %s, then %s", origPath, p)
+	}
+}
+
+func TestResolveProcessSecrets(t *testing.T) {
+	t.Setenv("GHOST_TEST_SECRET", "s3cret")
+	envFile := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(envFile, []byte("DATABASE_URL=file:data.db\nREF=env:GHOST_TEST_SECRET\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	spec, err := resolveProcess(context.Background(), McpConfig{
+		Name:    "db",
+		Command: "db-mcp",
+		EnvFile: envFile,
+		Env:     []string{"TOKEN=env:GHOST_TEST_SECRET"},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"DATABASE_URL=file:data.db", "REF=env:GHOST_TEST_SECRET", "TOKEN=s3cret"}
+	if !slices.Equal(spec.env, want) {
+		t.Errorf("env = %q, want %q", spec.env, want)
+	}
+}
+
+func TestCommandPath(t *testing.T) {
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin", "srv")
+	if err := os.MkdirAll(filepath.Dir(bin), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	envFile := filepath.Join(dir, ".env")
+	if err := os.WriteFile(envFile, []byte("BIN_DIR="+filepath.Dir(bin)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		cfg     McpConfig
+		want    string
+		wantErr bool
+	}{
+		{McpConfig{Command: "${BIN_DIR}/srv", EnvFile: envFile}, bin, false},
+		{McpConfig{Command: "${SRV}", Env: []string{"SRV=" + bin, "TOKEN=cmd:ghost-test-no-such-command"}}, bin, false},
+		{McpConfig{Command: "bin/srv", Cwd: dir}, bin, false},
+		{McpConfig{Command: "bin/srv"}, "", true},
+		{McpConfig{Command: "${GHOST_TEST_UNSET}/srv"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := tt.cfg.CommandPath()
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("CommandPath(%+v) = %q, %v", tt.cfg, got, err)
+		}
+	}
+}
+
+func TestInheritEnv(t *testing.T) {
+	t.Setenv("GHOST_TEST_API_KEY", "leak")
+	noInherit := false
+	tests := []struct {
+		cfg  McpConfig
+		want string
+	}{
+		{McpConfig{AutoApprove: []string{"*"}}, "GHOST_TEST_API_KEY=leak"},
+		{McpConfig{AutoApprove: []string{"*"}, InheritEnv: &noInherit}, "GHOST_TEST_API_KEY="},
+		{McpConfig{AutoApprove: []string{"*"}, InheritEnv: &noInherit, Env: []string{"GHOST_TEST_API_KEY=given"}}, "GHOST_TEST_API_KEY=given"},
+	}
+	for _, tt := range tests {
+		rt := startTestRuntime(t, tt.cfg)
+		if got := callText(t, rt, "env", map[string]any{"name": "GHOST_TEST_API_KEY"}); got != tt.want {
+			t.Errorf("InheritEnv %v, Env %q: server sees %q, want %q", tt.cfg.InheritEnv, tt.cfg.Env, got, tt.want)
+		}
+	}
+}
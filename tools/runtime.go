@@ -0,0 +1,182 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"sync"
+
+	"github.com/kk2simon/ghost-cli/base"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// DefaultMaxToolFailures is the MaxToolFailures used when it is not set.
+const DefaultMaxToolFailures = 5
+
+type Runtime struct {
+	Caller    ToolCaller
+	CloseFunc func()
+
+	// Confirm is asked about calls not covered by McpConfig.AutoApprove.
+	// It defaults to ConsoleConfirm.
+	Confirm ConfirmFunc
+
+	// Redactor, if set, masks secrets in tool results before they reach the LLM.
+	Redactor *base.Redactor
+
+	// MaxToolFailures is the number of consecutive failed tool calls after
+	// which a chat stops running tools until the user's next message.
+	// 0 means DefaultMaxToolFailures, a negative value means no limit.
+	MaxToolFailures int
+
+	// Failures holds the start errors of the servers ghost runs without, by name.
+	Failures map[string]error
+
+	logger *slog.Logger
+
+	mu             sync.RWMutex
+	servers        []*mcpServer
+	tools          []mcp.Tool
+	routes         map[string]toolRoute
+	version        uint64
+	onToolsChanged func()
+
+	cache resultCache
+
+	progressMu    sync.Mutex
+	progressSeq   int
+	progressShown map[mcp.ProgressToken]progressView
+}
+
+// mcpServer is a started MCP client together with the tools it currently offers.
+type mcpServer struct {
+	cfg McpConfig
+	// client is nil until a lazy server is started, both are guarded by Runtime.mu
+	client  *client.Client
+	cmd     *exec.Cmd
+	startMu sync.Mutex
+	// tools as offered to the LLM, filtered and renamed with cfg.Prefix
+	tools []mcp.Tool
+	// toolNames maps offered tool names back to the server's names
+	toolNames map[string]string
+}
+
+// toolRoute is where calls to an offered tool go.
+type toolRoute struct {
+	server *mcpServer
+	tool   mcp.Tool // as offered to the LLM
+	name   string   // the server's name for the tool
+}
+
+// serverTool returns the tool as declared by the server, under its own name.
+func (r toolRoute) serverTool() mcp.Tool {
+	t := r.tool
+	t.Name = r.name
+	return t
+}
+
+// setTools applies the config's filters and prefix to the tools listed by the server.
+func (s *mcpServer) setTools(listed []mcp.Tool) {
+	s.tools = make([]mcp.Tool, 0, len(listed))
+	s.toolNames = make(map[string]string, len(listed))
+	for _, t := range listed {
+		if !s.cfg.toolEnabled(t.Name) {
+			continue
+		}
+		name := t.Name
+		t.Name = s.cfg.Prefix + name
+		s.toolNames[t.Name] = name
+		s.tools = append(s.tools, t)
+	}
+}
+
+// autoApproved reports whether the server's config approves tool without asking.
+func (s *mcpServer) autoApproved(tool string) bool {
+	return slices.Contains(s.cfg.AutoApprove, "*") || slices.Contains(s.cfg.AutoApprove, tool)
+}
+
+// Tools returns the tools of all servers and the version of that list.
+// The version changes whenever a server reports a new tool list, so callers
+// can cache whatever they derive from the tools until it moves.
+func (r *Runtime) Tools() ([]mcp.Tool, uint64) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.tools, r.version
+}
+
+// routeFor returns the route of an offered tool.
+func (r *Runtime) routeFor(tool string) (toolRoute, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	route, ok := r.routes[tool]
+	return route, ok
+}
+
+// toolNames returns the names of all offered tools.
+func (r *Runtime) toolNames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.tools))
+	for _, t := range r.tools {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// rebuildLocked recomputes the tool list and routing map from the servers.
+// r.mu must be held for writing.
+func (r *Runtime) rebuildLocked() {
+	allTools := make([]mcp.Tool, 0)
+	routes := make(map[string]toolRoute)
+	for _, s := range r.servers {
+		if s == nil {
+			continue
+		}
+		for _, t := range s.tools {
+			if other, ok := routes[t.Name]; ok {
+				r.logger.Warn("Duplicate tool name, set Prefix to tell them apart",
+					"tool", t.Name, "mcp", s.cfg.Name, "shadowedBy", other.server.cfg.Name)
+				continue
+			}
+			routes[t.Name] = toolRoute{server: s, tool: t, name: s.toolNames[t.Name]}
+			allTools = append(allTools, t)
+		}
+	}
+	r.tools = allTools
+	r.routes = routes
+	r.version++
+}
+
+// refreshTools re-lists the tools of s, after it sent notifications/tools/list_changed.
+func (r *Runtime) refreshTools(ctx context.Context, s *mcpServer) error {
+	c := r.clientOf(s)
+	if c == nil {
+		return nil // still starting, the start lists the tools
+	}
+	listCtx, cancel := context.WithTimeout(ctx, timeoutOr(s.cfg.ListTimeout))
+	defer cancel()
+	toolsResp, err := c.ListTools(listCtx, mcp.ListToolsRequest{})
+	if err != nil {
+		return fmt.Errorf("Failed to list tools of %s: %v", s.cfg.Name, err)
+	}
+	if s.cfg.Lazy {
+		if err := saveManifest(s.cfg, toolsResp.Tools); err != nil {
+			r.logger.Warn("Failed to save MCP tool manifest", "mcp", s.cfg.Name, "error", err)
+		}
+	}
+
+	r.mu.Lock()
+	s.setTools(toolsResp.Tools)
+	r.rebuildLocked()
+	onToolsChanged := r.onToolsChanged
+	r.mu.Unlock()
+
+	r.logger.Info("MCP tool list changed", "mcp", s.cfg.Name, "tools", len(toolsResp.Tools))
+	if onToolsChanged != nil {
+		onToolsChanged()
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package tools
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestToolListChanged(t *testing.T) {
+	rt := startTestRuntime(t, McpConfig{Prefix: "t_", AutoApprove: []string{"*"}})
+	hasGrown := func(ts []mcp.Tool) bool {
+		return slices.ContainsFunc(ts, func(tool mcp.Tool) bool { return tool.Name == "t_grown" })
+	}
+	ts, version := rt.Tools()
+	if hasGrown(ts) {
+		t.Fatal("t_grown listed before it was added")
+	}
+	changed := make(chan struct{}, 1)
+	rt.mu.Lock()
+	rt.onToolsChanged = func() { changed <- struct{}{} }
+	rt.mu.Unlock()
+
+	callText(t, rt, "t_grow", nil)
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("tool list not refreshed after notifications/tools/list_changed")
+	}
+	ts, newVersion := rt.Tools()
+	if !hasGrown(ts) || newVersion <= version {
+		t.Errorf("after the change: t_grown listed %v, version %d, want a version after %d", hasGrown(ts), newVersion, version)
+	}
+	if got := callText(t, rt, "t_grown", nil); !strings.HasPrefix(got, "grown ") {
+		t.Errorf("t_grown = %q", got)
+	}
+}
@@ -0,0 +1,28 @@
+package tools
+
+// SandboxConfig restricts the process of a stdio MCP server. It is only supported on Linux.
+// Without Isolate only the environment is scrubbed and rlimits set: the server
+// can still read everything ghost can, the home directory included.
+type SandboxConfig struct {
+	// Dir is the working directory of the server, defaults to McpConfig.Cwd, then ghost's.
+	Dir string
+	// KeepEnv lists variables passed on from ghost's environment, besides PATH and HOME.
+	// All other variables are scrubbed, McpConfig.Env and EnvFile are always passed.
+	KeepEnv []string
+
+	// CPUSeconds, MemoryMB (address space) and OpenFiles set rlimits of the
+	// server, 0 leaves a limit unchanged.
+	CPUSeconds uint64
+	MemoryMB   uint64
+	OpenFiles  uint64
+
+	// Isolate runs the server in new namespaces using bubblewrap (bwrap).
+	// The filesystem is mounted read-only and the home directory is hidden,
+	// apart from Dir and WritablePaths, which are writable, and ReadOnlyPaths.
+	// Dir can't be the home directory or contain it.
+	Isolate       bool
+	ReadOnlyPaths []string
+	WritablePaths []string
+	// Network keeps network access for an isolated server.
+	Network bool
+}
@@ -0,0 +1,134 @@
+//go:build linux
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// sandboxShimArg makes ghost act as the sandbox shim, see RunSandboxShim.
+const sandboxShimArg = "__ghost-sandbox-exec"
+
+// sandboxCommand returns the command starting the server of cfg in its sandbox.
+// ghost re-executes itself as a shim, which sets the rlimits and then execs the
+// server (through bwrap if isolated), so the limits hold from the first instruction.
+func sandboxCommand(ctx context.Context, cfg McpConfig, spec processSpec) (*exec.Cmd, error) {
+	sb := cfg.Sandbox
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find ghost executable: %w", err)
+	}
+
+	dir, err := serverDir(cfg, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	target := append([]string{spec.command}, spec.args...)
+	if sb.Isolate {
+		// binding dir would bring back the home directory bwrap hides
+		if home, err := os.UserHomeDir(); err == nil && withinDir(dir, home) {
+			return nil, fmt.Errorf("sandbox Dir %s of MCP %s contains the home directory, which Isolate hides; use a project directory", dir, cfg.Name)
+		}
+		if _, err := exec.LookPath("bwrap"); err != nil {
+			return nil, fmt.Errorf("sandbox Isolate of MCP %s needs bubblewrap (bwrap): %w", cfg.Name, err)
+		}
+		target = append(bwrapArgs(sb, dir), target...)
+	}
+
+	limits := fmt.Sprintf("%d,%d,%d", sb.CPUSeconds, sb.MemoryMB, sb.OpenFiles)
+	cmd := exec.CommandContext(ctx, self, append([]string{sandboxShimArg, limits}, target...)...)
+	cmd.Dir = dir
+	cmd.Env = sandboxEnv(sb, spec.env)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL}
+	return cmd, nil
+}
+
+// bwrapArgs returns the bubblewrap command line prefix for an isolated server running in dir.
+func bwrapArgs(sb *SandboxConfig, dir string) []string {
+	args := []string{"bwrap", "--die-with-parent", "--unshare-all",
+		"--ro-bind", "/", "/", "--dev", "/dev", "--proc", "/proc", "--tmpfs", "/tmp"}
+	if sb.Network {
+		args = append(args, "--share-net")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		args = append(args, "--tmpfs", home) // hides ~/.ssh and friends
+	}
+	for _, p := range sb.ReadOnlyPaths {
+		args = append(args, "--ro-bind", p, p)
+	}
+	args = append(args, "--bind", dir, dir)
+	for _, p := range sb.WritablePaths {
+		args = append(args, "--bind", p, p)
+	}
+	return append(args, "--chdir", dir, "--")
+}
+
+// withinDir tells whether path is dir or inside it.
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// sandboxEnv returns the scrubbed environment of a sandboxed server.
+func sandboxEnv(sb *SandboxConfig, extra []string) []string {
+	env := []string{}
+	for _, name := range append([]string{"PATH", "HOME"}, sb.KeepEnv...) {
+		if v, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+v)
+		}
+	}
+	return append(env, extra...)
+}
+
+// RunSandboxShim sets the rlimits passed by sandboxCommand and replaces the
+// process with the server. It returns right away unless ghost was started as the shim.
+func RunSandboxShim() {
+	if len(os.Args) < 4 || os.Args[1] != sandboxShimArg {
+		return
+	}
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "ghost sandbox: %v\n", err)
+		os.Exit(127)
+	}
+
+	limits := strings.Split(os.Args[2], ",")
+	resources := []struct {
+		resource int
+		scale    uint64
+	}{
+		{syscall.RLIMIT_CPU, 1},
+		{syscall.RLIMIT_AS, 1 << 20},
+		{syscall.RLIMIT_NOFILE, 1},
+	}
+	for i, r := range resources {
+		if i >= len(limits) {
+			break
+		}
+		n, err := strconv.ParseUint(limits[i], 10, 64)
+		if err != nil {
+			fail(fmt.Errorf("bad limit %q: %w", limits[i], err))
+		}
+		if n == 0 {
+			continue
+		}
+		lim := &syscall.Rlimit{Cur: n * r.scale, Max: n * r.scale}
+		if err := syscall.Setrlimit(r.resource, lim); err != nil {
+			fail(fmt.Errorf("failed to set rlimit: %w", err))
+		}
+	}
+
+	target := os.Args[3:]
+	path, err := exec.LookPath(target[0])
+	if err != nil {
+		fail(err)
+	}
+	fail(syscall.Exec(path, target, os.Environ()))
+}
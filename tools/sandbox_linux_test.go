@@ -0,0 +1,156 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBwrapArgs(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, "project") // inside the hidden home
+
+	args := bwrapArgs(&SandboxConfig{
+		ReadOnlyPaths: []string{"/opt/data"},
+		WritablePaths: []string{"/var/cache/app"},
+	}, dir)
+	joined := strings.Join(args, " ")
+
+	tmpfsHome := strings.Index(joined, "--tmpfs "+home+" ")
+	if tmpfsHome < 0 {
+		t.Fatalf("home is not hidden, ~/.ssh stays readable: %s", joined)
+	}
+	for _, want := range []string{
+		"--unshare-all",
+		"--ro-bind / /",
+		"--ro-bind /opt/data /opt/data",
+		"--bind " + dir + " " + dir,
+		"--bind /var/cache/app /var/cache/app",
+	} {
+		i := strings.Index(joined, want)
+		if i < 0 {
+			t.Errorf("missing %q in %s", want, joined)
+		}
+		// mounts after the tmpfs are visible on top of it
+		if strings.HasPrefix(want, "--bind") && i < tmpfsHome {
+			t.Errorf("%q comes before the home tmpfs, which hides it: %s", want, joined)
+		}
+	}
+	if slices.Contains(args, "--share-net") {
+		t.Error("network shared without Network")
+	}
+	if !slices.Equal(args[len(args)-3:], []string{"--chdir", dir, "--"}) {
+		t.Errorf("args end with %q", args[len(args)-3:])
+	}
+
+	if args := bwrapArgs(&SandboxConfig{Network: true}, dir); !slices.Contains(args, "--share-net") {
+		t.Errorf("network not shared with Network: %s", args)
+	}
+}
+
+func TestSandboxEnv(t *testing.T) {
+	t.Setenv("HOME", "/home/me")
+	t.Setenv("PATH", "/usr/bin")
+	t.Setenv("LANG", "C.UTF-8")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "leak")
+	t.Setenv("GITHUB_TOKEN", "leak")
+
+	env := sandboxEnv(&SandboxConfig{KeepEnv: []string{"LANG", "GHOST_TEST_UNSET"}}, []string{"DB=app.db"})
+	want := []string{"PATH=/usr/bin", "HOME=/home/me", "LANG=C.UTF-8", "DB=app.db"}
+	if !slices.Equal(env, want) {
+		t.Errorf("sandboxEnv() = %q, want %q", env, want)
+	}
+}
+
+func TestSandboxCommand(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "leak")
+	dir := t.TempDir()
+	cfg := McpConfig{Name: "fs", Sandbox: &SandboxConfig{Dir: dir, CPUSeconds: 10, MemoryMB: 512}}
+	spec := processSpec{command: "mcp-fs", args: []string{"--root", "."}, env: []string{"A=1"}}
+
+	cmd, err := sandboxCommand(context.Background(), cfg, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{sandboxShimArg, "10,512,0", "mcp-fs", "--root", "."}; !slices.Equal(cmd.Args[1:], want) {
+		t.Errorf("args = %q, want %q", cmd.Args[1:], want)
+	}
+	if cmd.Dir != dir {
+		t.Errorf("dir = %s, want %s", cmd.Dir, dir)
+	}
+	if slices.ContainsFunc(cmd.Env, func(kv string) bool { return strings.HasPrefix(kv, "GITHUB_TOKEN=") }) || !slices.Contains(cmd.Env, "A=1") {
+		t.Errorf("env = %q", cmd.Env)
+	}
+}
+
+func TestSandboxHomeDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	spec := processSpec{command: "mcp-fs"}
+	for _, dir := range []string{home, filepath.Dir(home), "/"} {
+		cfg := McpConfig{Name: "fs", Sandbox: &SandboxConfig{Dir: dir, Isolate: true}}
+		if _, err := sandboxCommand(context.Background(), cfg, spec); err == nil || !strings.Contains(err.Error(), "home directory") {
+			t.Errorf("Dir %s: error %v, want the home directory refused", dir, err)
+		}
+	}
+	// without Isolate nothing is hidden, so any Dir will do
+	cfg := McpConfig{Name: "fs", Sandbox: &SandboxConfig{Dir: home}}
+	if _, err := sandboxCommand(context.Background(), cfg, spec); err != nil {
+		t.Errorf("Dir %s without Isolate: %v", home, err)
+	}
+	if withinDir(filepath.Join(home, "project"), home) || withinDir(home+"2", home) {
+		t.Error("withinDir matches a directory inside or next to home")
+	}
+}
+
+func TestSandboxShim(t *testing.T) {
+	// the test binary is the shim, see TestMain
+	out, err := exec.Command(os.Args[0], sandboxShimArg, "30,256,64", "/bin/sh", "-c", "ulimit -t; ulimit -v; ulimit -n").CombinedOutput()
+	if err != nil {
+		t.Fatalf("shim: %v: %s", err, out)
+	}
+	if got, want := strings.Fields(string(out)), []string{"30", "262144", "64"}; !slices.Equal(got, want) {
+		t.Errorf("limits (cpu s, memory KB, files) = %q, want %q", got, want)
+	}
+
+	out, err = exec.Command(os.Args[0], sandboxShimArg, "x,0,0", "/bin/true").CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "bad limit") {
+		t.Errorf("shim with a bad limit = %v: %s", err, out)
+	}
+}
+
+func TestSandboxedServer(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "leak")
+	rt := startTestRuntime(t, McpConfig{
+		AutoApprove: []string{"*"},
+		Env:         []string{"DB=app.db"},
+		Sandbox:     &SandboxConfig{Dir: t.TempDir(), CPUSeconds: 60, OpenFiles: 256},
+	})
+	if got := callText(t, rt, "env", map[string]any{"name": "GITHUB_TOKEN"}); got != "GITHUB_TOKEN=" {
+		t.Errorf("sandboxed server sees %q", got)
+	}
+	if got := callText(t, rt, "env", map[string]any{"name": "DB"}); got != "DB=app.db" {
+		t.Errorf("sandboxed server env = %q, want DB=app.db", got)
+	}
+
+	// the shim execs the server, so the limits hold in the server's process
+	rt.mu.RLock()
+	pid := rt.servers[0].cmd.Process.Pid
+	rt.mu.RUnlock()
+	limits, err := os.ReadFile(fmt.Sprintf("/proc/%d/limits", pid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`Max cpu time\s+60\s+60`, `Max open files\s+256\s+256`} {
+		if !regexp.MustCompile(want).Match(limits) {
+			t.Errorf("server limits lack %q:\n%s", want, limits)
+		}
+	}
+}
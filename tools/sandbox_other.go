@@ -0,0 +1,16 @@
+//go:build !linux
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+func sandboxCommand(ctx context.Context, cfg McpConfig, spec processSpec) (*exec.Cmd, error) {
+	return nil, fmt.Errorf("MCP %s: Sandbox is only supported on Linux", cfg.Name)
+}
+
+// RunSandboxShim does nothing, sandboxes are only supported on Linux.
+func RunSandboxShim() {}
@@ -0,0 +1,241 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// inputSchema returns the input schema of t as a plain JSON value,
+// whether the tool was declared with InputSchema or RawInputSchema.
+func inputSchema(t mcp.Tool) (map[string]any, error) {
+	raw, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	var decoded struct {
+		InputSchema map[string]any `json:"inputSchema"`
+	}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, err
+	}
+	return decoded.InputSchema, nil
+}
+
+// validateArguments checks arguments against a JSON Schema and returns a
+// message per violation. It covers the keywords tool schemas use in practice:
+// type, enum, const, properties, required, additionalProperties, items,
+// length, range and pattern constraints, and allOf/anyOf/oneOf. Others,
+// such as $ref, are not checked.
+func validateArguments(schema map[string]any, arguments map[string]any) []string {
+	var args any = arguments
+	if arguments == nil {
+		args = map[string]any{}
+	}
+	var errs []string
+	validateValue(schema, args, "$", &errs)
+	return errs
+}
+
+func validateValue(schema map[string]any, v any, path string, errs *[]string) {
+	addErr := func(format string, a ...any) {
+		*errs = append(*errs, path+": "+fmt.Sprintf(format, a...))
+	}
+
+	if t, ok := schema["type"]; ok && !matchesType(t, v) {
+		addErr("expected %s, got %s", typeString(t), jsonType(v))
+		return // other keywords don't make sense for the wrong type
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.ContainsFunc(enum, func(e any) bool { return jsonEqual(e, v) }) {
+		addErr("must be one of %s", mustJSON(enum))
+	}
+	if c, ok := schema["const"]; ok && !jsonEqual(c, v) {
+		addErr("must be %s", mustJSON(c))
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		validateObject(schema, val, path, errs)
+	case []any:
+		if min, ok := number(schema["minItems"]); ok && float64(len(val)) < min {
+			addErr("must have at least %g items", min)
+		}
+		if max, ok := number(schema["maxItems"]); ok && float64(len(val)) > max {
+			addErr("must have at most %g items", max)
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range val {
+				validateValue(items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case string:
+		length := float64(len([]rune(val)))
+		if min, ok := number(schema["minLength"]); ok && length < min {
+			addErr("must be at least %g characters long", min)
+		}
+		if max, ok := number(schema["maxLength"]); ok && length > max {
+			addErr("must be at most %g characters long", max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(val) {
+				addErr("must match pattern %q", pattern)
+			}
+		}
+	default:
+		if n, ok := number(v); ok {
+			if min, ok := number(schema["minimum"]); ok && n < min {
+				addErr("must be >= %g", min)
+			}
+			if max, ok := number(schema["maximum"]); ok && n > max {
+				addErr("must be <= %g", max)
+			}
+			if min, ok := number(schema["exclusiveMinimum"]); ok && n <= min {
+				addErr("must be > %g", min)
+			}
+			if max, ok := number(schema["exclusiveMaximum"]); ok && n >= max {
+				addErr("must be < %g", max)
+			}
+		}
+	}
+
+	if all, ok := schema["allOf"].([]any); ok {
+		for _, sub := range all {
+			if subSchema, ok := sub.(map[string]any); ok {
+				validateValue(subSchema, v, path, errs)
+			}
+		}
+	}
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		alternatives, ok := schema[keyword].([]any)
+		if !ok {
+			continue
+		}
+		matched := 0
+		for _, sub := range alternatives {
+			if subSchema, ok := sub.(map[string]any); ok {
+				var subErrs []string
+				validateValue(subSchema, v, path, &subErrs)
+				if len(subErrs) == 0 {
+					matched++
+				}
+			}
+		}
+		if matched == 0 || (keyword == "oneOf" && matched > 1) {
+			addErr("must match %s %s", map[string]string{"anyOf": "at least one of", "oneOf": "exactly one of"}[keyword], mustJSON(alternatives))
+		}
+	}
+}
+
+func validateObject(schema map[string]any, obj map[string]any, path string, errs *[]string) {
+	properties, _ := schema["properties"].(map[string]any)
+
+	if required, ok := schema["required"].([]any); ok {
+		for _, r := range required {
+			if name, ok := r.(string); ok {
+				if _, present := obj[name]; !present {
+					*errs = append(*errs, fmt.Sprintf("%s: missing required property %q", path, name))
+				}
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		propPath := path + "." + k
+		if propSchema, ok := properties[k].(map[string]any); ok {
+			validateValue(propSchema, obj[k], propPath, errs)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				known := make([]string, 0, len(properties))
+				for name := range properties {
+					known = append(known, name)
+				}
+				sort.Strings(known)
+				*errs = append(*errs, fmt.Sprintf("%s: unknown property, expected one of %s", propPath, strings.Join(known, ", ")))
+			}
+		case map[string]any:
+			validateValue(additional, obj[k], propPath, errs)
+		}
+	}
+}
+
+// matchesType reports whether v has the schema type t, a name or a list of names.
+func matchesType(t any, v any) bool {
+	switch typ := t.(type) {
+	case string:
+		actual := jsonType(v)
+		return actual == typ || (typ == "number" && actual == "integer")
+	case []any:
+		return slices.ContainsFunc(typ, func(one any) bool { return matchesType(one, v) })
+	}
+	return true // unknown type keyword, don't guess
+}
+
+func typeString(t any) string {
+	if s, ok := t.(string); ok {
+		return s
+	}
+	return mustJSON(t)
+}
+
+// jsonType returns the JSON Schema type name of a decoded JSON value.
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	if n, ok := number(v); ok {
+		if n == math.Trunc(n) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func jsonEqual(a, b any) bool {
+	return mustJSON(a) == mustJSON(b)
+}
+
+func mustJSON(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,42 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestValidateArguments(t *testing.T) {
+	tool := mcp.NewTool("edit_file",
+		mcp.WithString("path", mcp.Required()),
+		mcp.WithNumber("line", mcp.Min(1)),
+		mcp.WithString("mode", mcp.Enum("replace", "insert")),
+		mcp.WithArray("edits", mcp.Items(map[string]any{"type": "string"})),
+	)
+	schema, err := inputSchema(tool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args map[string]any
+		want []string
+	}{
+		{"valid", map[string]any{"path": "a.go", "line": 3.0, "mode": "insert", "edits": []any{"x"}}, nil},
+		{"missing required", map[string]any{}, []string{`$: missing required property "path"`}},
+		{"wrong type", map[string]any{"path": 1.0}, []string{"$.path: expected string, got integer"}},
+		{"below minimum", map[string]any{"path": "a", "line": 0.0}, []string{"$.line: must be >= 1"}},
+		{"not in enum", map[string]any{"path": "a", "mode": "append"}, []string{`$.mode: must be one of ["replace","insert"]`}},
+		{"bad item", map[string]any{"path": "a", "edits": []any{"x", true}}, []string{"$.edits[1]: expected string, got boolean"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validateArguments(schema, tt.args)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}